@@ -0,0 +1,184 @@
+package Netpbm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ASCIILayout selects how samples are split into lines when saving an ASCII (P2 or P3) image.
+type ASCIILayout int
+
+const (
+	// LayoutRow writes each image row on its own line.
+	LayoutRow ASCIILayout = iota
+	// LayoutPixel writes each pixel on its own line. For PGM images this is the same as LayoutSample.
+	LayoutPixel
+	// LayoutSample writes each sample on its own line.
+	LayoutSample
+)
+
+// maxLineWidth is the longest line the Netpbm specification allows in ASCII images.
+const maxLineWidth = 70
+
+// ASCIIOptions controls the output of ASCII images.
+type ASCIIOptions struct {
+	Layout ASCIILayout
+	// MaxLineWidth wraps lines before they exceed this many characters. Zero means no limit.
+	MaxLineWidth int
+}
+
+// asciiWriter writes space-separated samples, wrapping lines at maxWidth characters.
+// The first write error is kept in err and later writes are skipped.
+type asciiWriter struct {
+	w        *bufio.Writer
+	maxWidth int
+	lineLen  int
+	err      error
+}
+
+// sample writes a sample, preceded by a space or a newline if the line is not empty.
+func (a *asciiWriter) sample(value uint) {
+	s := strconv.FormatUint(uint64(value), 10)
+	if a.lineLen > 0 {
+		if a.maxWidth > 0 && a.lineLen+1+len(s) > a.maxWidth {
+			a.newline()
+		} else {
+			a.write(" ")
+		}
+	}
+	a.write(s)
+}
+
+// newline ends the current line if it is not empty.
+func (a *asciiWriter) newline() {
+	if a.lineLen > 0 {
+		a.write("\n")
+		a.lineLen = 0
+	}
+}
+
+// write writes s and tracks the length of the current line.
+func (a *asciiWriter) write(s string) {
+	if a.err != nil {
+		return
+	}
+	_, a.err = a.w.WriteString(s)
+	a.lineLen += len(s)
+}
+
+// maxHeaderFieldSize bounds the size of a header line or token, so corrupt input cannot be read into memory unboundedly.
+const maxHeaderFieldSize = 1024
+
+// readLine reads up to and including the next newline, failing if the line is longer than maxHeaderFieldSize bytes.
+func readLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			return string(line), nil
+		}
+		if len(line) >= maxHeaderFieldSize {
+			return "", fmt.Errorf("line longer than %d bytes", maxHeaderFieldSize)
+		}
+	}
+}
+
+// readToken reads the next whitespace-separated token one byte at a time, skipping comments.
+// It never reads past the single whitespace character that ends the token, except for the "\n" of a CRLF pair,
+// so binary data after a CRLF header starts at the right byte. A token ended by the end of the input is returned without error.
+func readToken(r io.Reader) (string, error) {
+	var token []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return string(token), nil
+			}
+			return "", err
+		}
+
+		switch {
+		case b[0] == '#' && len(token) == 0:
+			// Skip the comment up to the end of the line
+			for n := 0; b[0] != '\n'; n++ {
+				if n >= maxHeaderFieldSize {
+					return "", fmt.Errorf("comment longer than %d bytes", maxHeaderFieldSize)
+				}
+				if _, err := io.ReadFull(r, b); err != nil {
+					return "", err
+				}
+			}
+		case b[0] == ' ' || b[0] == '\t' || b[0] == '\n' || b[0] == '\r' || b[0] == '\v' || b[0] == '\f':
+			if len(token) > 0 {
+				if b[0] == '\r' {
+					if err := skipLineFeed(r); err != nil {
+						return "", err
+					}
+				}
+				return string(token), nil
+			}
+		default:
+			if len(token) >= maxHeaderFieldSize {
+				return "", fmt.Errorf("token longer than %d bytes", maxHeaderFieldSize)
+			}
+			token = append(token, b[0])
+		}
+	}
+}
+
+// skipLineFeed consumes the next byte of r if it is a '\n'. Any other byte is put back, which requires r to be
+// an io.ByteScanner or an io.Seeker; for other readers nothing is consumed.
+func skipLineFeed(r io.Reader) error {
+	switch r := r.(type) {
+	case io.ByteScanner:
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if b != '\n' {
+			return r.UnreadByte()
+		}
+	case io.ReadSeeker:
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if b[0] != '\n' {
+			_, err := r.Seek(-1, io.SeekCurrent)
+			return err
+		}
+	}
+	return nil
+}
+
+// readDimensions reads the width and height as the next two integer tokens, so they may be separated by any
+// whitespace, including tabs and newlines, and by comments.
+func readDimensions(r io.Reader) (width, height int, err error) {
+	var values [2]int
+	for i := range values {
+		token, err := readToken(r)
+		if err != nil {
+			return 0, 0, err
+		}
+		values[i], err = strconv.Atoi(token)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid integer %q", token)
+		}
+		if values[i] < 0 {
+			return 0, 0, fmt.Errorf("negative dimension %d", values[i])
+		}
+	}
+	return values[0], values[1], nil
+}
@@ -0,0 +1,180 @@
+package Netpbm
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dataLines returns the lines of an ASCII image file after its three header lines.
+func dataLines(t *testing.T, filename string) []string {
+	t.Helper()
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("file has %d lines, want a 3-line header", len(lines))
+	}
+	return lines[3:]
+}
+
+func TestSaveASCIILayouts(t *testing.T) {
+	pgm := noisePGM(4, 3)
+	ppm := patternPPM(4, 3)
+
+	tests := []struct {
+		name          string
+		ppm           bool
+		layout        ASCIILayout
+		lines, fields int
+	}{
+		{"PGM row", false, LayoutRow, 3, 4},
+		{"PGM sample", false, LayoutSample, 12, 1},
+		{"PPM row", true, LayoutRow, 3, 12},
+		{"PPM pixel", true, LayoutPixel, 12, 3},
+		{"PPM sample", true, LayoutSample, 36, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "layout")
+			opts := ASCIIOptions{Layout: tt.layout}
+			var err error
+			if tt.ppm {
+				err = ppm.SaveWithOptions(filename, opts)
+			} else {
+				err = pgm.SaveWithOptions(filename, opts)
+			}
+			if err != nil {
+				t.Fatalf("SaveWithOptions: %v", err)
+			}
+
+			lines := dataLines(t, filename)
+			if len(lines) != tt.lines {
+				t.Fatalf("%d data lines, want %d", len(lines), tt.lines)
+			}
+			for i, line := range lines {
+				if n := len(strings.Fields(line)); n != tt.fields {
+					t.Errorf("line %d has %d samples, want %d: %q", i, n, tt.fields, line)
+				}
+			}
+
+			// Every layout parses back to the same image
+			if tt.ppm {
+				got, err := ReadPPM(filename)
+				if err != nil {
+					t.Fatalf("ReadPPM: %v", err)
+				}
+				if !reflect.DeepEqual(got.data, ppm.data) {
+					t.Errorf("read back %v, want %v", got.data, ppm.data)
+				}
+			} else {
+				got, err := ReadPGM(filename)
+				if err != nil {
+					t.Fatalf("ReadPGM: %v", err)
+				}
+				if !reflect.DeepEqual(got.data, pgm.data) {
+					t.Errorf("read back %v, want %v", got.data, pgm.data)
+				}
+			}
+		})
+	}
+}
+
+func TestSaveWrapsLongLines(t *testing.T) {
+	pgm := noisePGM(100, 3)
+	ppm := patternPPM(100, 3)
+	for i := range ppm.data {
+		for j := range ppm.data[i] {
+			ppm.data[i][j] = Pixel{200, 201, 202}
+		}
+	}
+
+	dir := t.TempDir()
+	pgmFile, ppmFile := filepath.Join(dir, "wide.pgm"), filepath.Join(dir, "wide.ppm")
+	if err := pgm.Save(pgmFile); err != nil {
+		t.Fatalf("PGM Save: %v", err)
+	}
+	// Use a row layout so unwrapped PPM lines would be far longer than the limit
+	if err := ppm.SaveWithOptions(ppmFile, ASCIIOptions{Layout: LayoutRow, MaxLineWidth: maxLineWidth}); err != nil {
+		t.Fatalf("PPM SaveWithOptions: %v", err)
+	}
+
+	for _, filename := range []string{pgmFile, ppmFile} {
+		for i, line := range dataLines(t, filename) {
+			if len(line) > 70 {
+				t.Errorf("%s: line %d is %d characters long", filepath.Base(filename), i, len(line))
+			}
+		}
+	}
+
+	gotPGM, err := ReadPGM(pgmFile)
+	if err != nil {
+		t.Fatalf("ReadPGM: %v", err)
+	}
+	if !reflect.DeepEqual(gotPGM.data, pgm.data) {
+		t.Error("PGM did not round-trip")
+	}
+	gotPPM, err := ReadPPM(ppmFile)
+	if err != nil {
+		t.Fatalf("ReadPPM: %v", err)
+	}
+	if !reflect.DeepEqual(gotPPM.data, ppm.data) {
+		t.Error("PPM did not round-trip")
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestHugeHeaderFieldFailsFast(t *testing.T) {
+	huge := bytes.Repeat([]byte("1"), 4<<20)
+	tests := []struct {
+		name   string
+		prefix string
+		fill   []byte
+	}{
+		{"magic number", "P3", huge},
+		{"width", "P3\n", huge},
+		{"max value", "P3\n2 2\n", huge},
+		{"comment", "P3\n#", bytes.Repeat([]byte("x"), 4<<20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &countingReader{r: io.MultiReader(strings.NewReader(tt.prefix), bytes.NewReader(tt.fill))}
+			if _, err := decodePPM(r); err == nil {
+				t.Fatal("expected an error")
+			}
+			// The reader gives up after about one header field instead of consuming the whole input
+			if r.n > 64<<10 {
+				t.Errorf("read %d bytes before failing", r.n)
+			}
+		})
+	}
+
+	// The file readers fail the same way
+	dir := t.TempDir()
+	for _, magic := range []string{"P1", "P2", "P3"} {
+		filename := filepath.Join(dir, magic)
+		if err := os.WriteFile(filename, append([]byte(magic+"\n"), huge...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Read(filename); err == nil {
+			t.Errorf("Read of a %s file with a huge header: expected an error", magic)
+		}
+	}
+}
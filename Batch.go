@@ -0,0 +1,131 @@
+package Netpbm
+
+import (
+	"errors"
+	"fmt"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BatchConvert converts every PBM, PGM and PPM file found under srcDir to the format given by dstExt
+// (".pbm", ".pgm", ".ppm" or ".png") and writes it to the same relative path under dstDir.
+// Files are converted concurrently by workers goroutines. The errors of all failed files are joined and returned;
+// files that cannot be read, such as binary PPM (P6) files, are skipped and reported with ErrUnsupportedFormat
+// while the other files are still converted.
+func BatchConvert(srcDir, dstDir, dstExt string, workers int) error {
+	dstExt = strings.ToLower(dstExt)
+	if !strings.HasPrefix(dstExt, ".") {
+		dstExt = "." + dstExt
+	}
+	switch dstExt {
+	case ".pbm", ".pgm", ".ppm", ".png":
+	default:
+		return fmt.Errorf("unsupported destination format: %s", dstExt)
+	}
+	workers = max(workers, 1)
+
+	var sources []string
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".pbm", ".pgm", ".ppm":
+			if !d.IsDir() {
+				sources = append(sources, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking %s: %v", srcDir, err)
+	}
+
+	paths := make(chan string)
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if err := convertFile(srcDir, dstDir, path, dstExt); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s: %w", path, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, path := range sources {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// convertFile reads the image at path, converts it to dstExt and saves it under dstDir,
+// at the same path relative to srcDir.
+func convertFile(srcDir, dstDir, path, dstExt string) error {
+	img, err := Read(path)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(srcDir, path)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(dstDir, strings.TrimSuffix(rel, filepath.Ext(rel))+dstExt)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	if dstExt == ".png" {
+		file, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(file, img.ToImage()); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	return convertImage(img, dstExt).Save(dst)
+}
+
+// convertImage converts img to the Netpbm type matching ext, returning img itself if it already has that type.
+func convertImage(img Image, ext string) Image {
+	switch src := img.(type) {
+	case *PBM:
+		switch ext {
+		case ".pgm":
+			return src.ToPGM()
+		case ".ppm":
+			return src.ToPGM().ToPPM()
+		}
+	case *PGM:
+		switch ext {
+		case ".pbm":
+			return src.ToPBM()
+		case ".ppm":
+			return src.ToPPM()
+		}
+	case *PPM:
+		switch ext {
+		case ".pbm":
+			return src.ToPBM()
+		case ".pgm":
+			return src.ToPGM()
+		}
+	}
+	return img
+}
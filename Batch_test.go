@@ -0,0 +1,58 @@
+package Netpbm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBatchConvert(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		"bitmap.pbm":     "P1\n2 2\n1 0\n0 1\n",
+		"gray.pgm":       "P2\n2 2\n255\n0 64\n128 255\n",
+		"sub/color.ppm":  "P3\n2 2\n255\n255 0 0 0 255 0\n0 0 255 255 255 255\n",
+		"ignored.txt":    "not an image",
+		"sub/binary.ppm": "P6\n1 1\n255\n\xff\x00\x00",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := BatchConvert(src, dst, "ppm", 2)
+	// The binary PPM is skipped and reported, the other files are still converted
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("BatchConvert error = %v, want ErrUnsupportedFormat for the P6 file", err)
+	}
+	for _, name := range []string{"bitmap.ppm", "gray.ppm", "sub/color.ppm"} {
+		ppm, err := ReadPPM(filepath.Join(dst, name))
+		if err != nil {
+			t.Errorf("output %s: %v", name, err)
+			continue
+		}
+		if ppm.magicNumber != "P3" || ppm.width != 2 || ppm.height != 2 {
+			t.Errorf("output %s is %q %dx%d, want a 2x2 P3 image", name, ppm.magicNumber, ppm.width, ppm.height)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "sub/binary.ppm")); !os.IsNotExist(err) {
+		t.Errorf("the P6 file was written to the output: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "ignored.ppm")); !os.IsNotExist(err) {
+		t.Errorf("a non-image file was converted: %v", err)
+	}
+
+	// A directory of supported files converts without error
+	if err := os.Remove(filepath.Join(src, "sub/binary.ppm")); err != nil {
+		t.Fatal(err)
+	}
+	if err := BatchConvert(src, t.TempDir(), ".pgm", 3); err != nil {
+		t.Errorf("BatchConvert: %v", err)
+	}
+}
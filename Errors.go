@@ -0,0 +1,13 @@
+package Netpbm
+
+import "errors"
+
+// Errors returned by the readers, wrapped with details about the failure. Use errors.Is to test for them.
+var (
+	// ErrUnsupportedFormat is returned when the magic number is not one the reader handles.
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	// ErrInvalidHeader is returned when the dimensions or max value are missing or malformed.
+	ErrInvalidHeader = errors.New("invalid header")
+	// ErrTruncated is returned when the file ends before all the pixel data is read.
+	ErrTruncated = errors.New("unexpected end of file")
+)
@@ -0,0 +1,222 @@
+package Netpbm
+
+import (
+	"math"
+	"sync"
+)
+
+// gaussianKernel returns a normalized 1D Gaussian kernel covering three standard deviations on each side.
+func gaussianKernel(sigma float64) []float64 {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+// convolvePlane applies the horizontal then the vertical 1D kernel to a plane of samples.
+// Both kernels are centered on the pixel and samples outside the plane are clamped to the nearest edge.
+func convolvePlane(plane [][]float64, horiz, vert []float64) [][]float64 {
+	return convolvePlaneParallel(plane, horiz, vert, 1)
+}
+
+// convolvePlaneParallel is like convolvePlane but splits the rows of each pass between workers goroutines.
+// The vertical pass only starts once the whole intermediate plane has been written by the horizontal pass,
+// so the result is identical to convolvePlane.
+func convolvePlaneParallel(plane [][]float64, horiz, vert []float64, workers int) [][]float64 {
+	height := len(plane)
+	if height == 0 {
+		return plane
+	}
+	width := len(plane[0])
+
+	tmp := make([][]float64, height)
+	out := make([][]float64, height)
+	for y := range tmp {
+		tmp[y] = make([]float64, width)
+		out[y] = make([]float64, width)
+	}
+
+	// Horizontal pass
+	hr := len(horiz) / 2
+	parallelRows(height, workers, func(y int) {
+		for x := 0; x < width; x++ {
+			sum := 0.0
+			for k, weight := range horiz {
+				sx := min(max(x+k-hr, 0), width-1)
+				sum += weight * plane[y][sx]
+			}
+			tmp[y][x] = sum
+		}
+	})
+
+	// Vertical pass
+	vr := len(vert) / 2
+	parallelRows(height, workers, func(y int) {
+		for x := 0; x < width; x++ {
+			sum := 0.0
+			for k, weight := range vert {
+				sy := min(max(y+k-vr, 0), height-1)
+				sum += weight * tmp[sy][x]
+			}
+			out[y][x] = sum
+		}
+	})
+	return out
+}
+
+// parallelRows calls fn for every row in [0, height), splitting the rows into contiguous bands handled by
+// up to workers goroutines, and returns once all rows are done.
+func parallelRows(height, workers int, fn func(y int)) {
+	workers = min(max(workers, 1), height)
+	if workers <= 1 {
+		for y := 0; y < height; y++ {
+			fn(y)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	band := (height + workers - 1) / workers
+	for start := 0; start < height; start += band {
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for y := start; y < end; y++ {
+				fn(y)
+			}
+		}(start, min(start+band, height))
+	}
+	wg.Wait()
+}
+
+// scaledKernel returns a copy of kernel with every weight divided by divisor. A zero divisor is treated as 1.
+func scaledKernel(kernel []float64, divisor float64) []float64 {
+	if divisor == 0 {
+		divisor = 1
+	}
+	scaled := make([]float64, len(kernel))
+	for i, weight := range kernel {
+		scaled[i] = weight / divisor
+	}
+	return scaled
+}
+
+// unsharpPlane returns original + amount*(original - blurred) for every sample.
+func unsharpPlane(original, blurred [][]float64, amount float64) [][]float64 {
+	out := make([][]float64, len(original))
+	for y := range original {
+		out[y] = make([]float64, len(original[y]))
+		for x := range original[y] {
+			out[y][x] = original[y][x] + amount*(original[y][x]-blurred[y][x])
+		}
+	}
+	return out
+}
+
+// laplacianPlane applies the 3×3 Laplacian kernel to a plane of samples, clamping at the edges.
+func laplacianPlane(plane [][]float64) [][]float64 {
+	height := len(plane)
+	out := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		width := len(plane[y])
+		out[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			up := plane[max(y-1, 0)][x]
+			down := plane[min(y+1, height-1)][x]
+			left := plane[y][max(x-1, 0)]
+			right := plane[y][min(x+1, width-1)]
+			out[y][x] = up + down + left + right - 4*plane[y][x]
+		}
+	}
+	return out
+}
+
+// sobelPlane returns the horizontal and vertical Sobel gradients of a plane of samples, clamping at the edges.
+func sobelPlane(plane [][]float64) (gx, gy [][]float64) {
+	height := len(plane)
+	gx = make([][]float64, height)
+	gy = make([][]float64, height)
+	for y := 0; y < height; y++ {
+		width := len(plane[y])
+		gx[y] = make([]float64, width)
+		gy[y] = make([]float64, width)
+		at := func(sx, dy int) float64 {
+			return plane[min(max(y+dy, 0), height-1)][min(max(sx, 0), width-1)]
+		}
+		for x := 0; x < width; x++ {
+			gx[y][x] = at(x+1, -1) + 2*at(x+1, 0) + at(x+1, 1) - at(x-1, -1) - 2*at(x-1, 0) - at(x-1, 1)
+			gy[y][x] = at(x-1, 1) + 2*at(x, 1) + at(x+1, 1) - at(x-1, -1) - 2*at(x, -1) - at(x+1, -1)
+		}
+	}
+	return gx, gy
+}
+
+// dctBlockSize is the size of the square blocks used by BlockDCT.
+const dctBlockSize = 8
+
+// dctBasis returns the orthonormal DCT-II basis: basis[u][x] is the weight of sample x in coefficient u.
+func dctBasis() [dctBlockSize][dctBlockSize]float64 {
+	var basis [dctBlockSize][dctBlockSize]float64
+	for u := 0; u < dctBlockSize; u++ {
+		scale := math.Sqrt(2.0 / dctBlockSize)
+		if u == 0 {
+			scale = math.Sqrt(1.0 / dctBlockSize)
+		}
+		for x := 0; x < dctBlockSize; x++ {
+			basis[u][x] = scale * math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*dctBlockSize))
+		}
+	}
+	return basis
+}
+
+// extremumFilter replaces every sample with the minimum (or the maximum when useMax is set) of the samples in the
+// (2*radius+1)×(2*radius+1) square around it, as a horizontal then a vertical pass. Samples outside the plane are ignored.
+func extremumFilter(data [][]uint8, radius int, useMax bool) [][]uint8 {
+	pick := func(a, b uint8) uint8 {
+		if useMax {
+			return max(a, b)
+		}
+		return min(a, b)
+	}
+
+	height := len(data)
+	if height == 0 {
+		return data
+	}
+	width := len(data[0])
+
+	// Horizontal pass
+	tmp := make([][]uint8, height)
+	for y := 0; y < height; y++ {
+		tmp[y] = make([]uint8, width)
+		for x := 0; x < width; x++ {
+			v := data[y][x]
+			for sx := max(x-radius, 0); sx <= min(x+radius, width-1); sx++ {
+				v = pick(v, data[y][sx])
+			}
+			tmp[y][x] = v
+		}
+	}
+
+	// Vertical pass
+	out := make([][]uint8, height)
+	for y := 0; y < height; y++ {
+		out[y] = make([]uint8, width)
+		for x := 0; x < width; x++ {
+			v := tmp[y][x]
+			for sy := max(y-radius, 0); sy <= min(y+radius, height-1); sy++ {
+				v = pick(v, tmp[sy][x])
+			}
+			out[y][x] = v
+		}
+	}
+	return out
+}
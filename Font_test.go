@@ -0,0 +1,67 @@
+package Netpbm
+
+import "testing"
+
+func TestDrawTextScale(t *testing.T) {
+	const scale = 2
+	ppm := newPPM(14, 18, "P3", 255)
+	origin := Point{1, 2}
+	ppm.DrawText(origin, "A", scale, white)
+
+	g := glyph('A')
+	lit := 0
+	for row := 0; row < glyphHeight; row++ {
+		for col := 0; col < glyphWidth; col++ {
+			want := black
+			if g[row]>>(glyphWidth-1-col)&1 == 1 {
+				want = white
+				lit++
+			}
+			// Every font pixel must cover a full scale×scale block
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					x, y := origin.X+col*scale+dx, origin.Y+row*scale+dy
+					if got := ppm.data[y][x]; got != want {
+						t.Errorf("pixel (%d, %d) of glyph cell (%d, %d) = %v, want %v", x, y, col, row, got, want)
+					}
+				}
+			}
+		}
+	}
+	if n := countColor(ppm, white); n != lit*scale*scale {
+		t.Errorf("%d pixels drawn, want %d", n, lit*scale*scale)
+	}
+}
+
+func TestMeasureText(t *testing.T) {
+	// Two 5×7 glyphs with one column of spacing between them
+	if w, h := MeasureText("AB", 1); w != 11 || h != 7 {
+		t.Errorf("MeasureText(\"AB\", 1) = %dx%d, want 11x7", w, h)
+	}
+	if w, h := MeasureText("AB\nC", 2); w != 22 || h != 30 {
+		t.Errorf("MeasureText(\"AB\\nC\", 2) = %dx%d, want 22x30", w, h)
+	}
+	if w, h := MeasureText("", 1); w != 0 || h != 0 {
+		t.Errorf("MeasureText(\"\", 1) = %dx%d, want 0x0", w, h)
+	}
+
+	// The measured box holds everything DrawText draws
+	ppm := newPPM(20, 10, "P3", 255)
+	ppm.DrawText(Point{0, 0}, "AB", 1, white)
+	for y := 0; y < ppm.height; y++ {
+		for x := 0; x < ppm.width; x++ {
+			if ppm.data[y][x] == white && (x >= 11 || y >= 7) {
+				t.Errorf("pixel (%d, %d) drawn outside the measured box", x, y)
+			}
+		}
+	}
+	lastColumn := 0
+	for y := 0; y < 7; y++ {
+		if ppm.data[y][10] == white {
+			lastColumn++
+		}
+	}
+	if lastColumn == 0 {
+		t.Error("the last column of the measured box is empty")
+	}
+}
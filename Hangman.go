@@ -0,0 +1,40 @@
+package Netpbm
+
+// Dimensions of the images produced by DrawHangmanStage.
+const (
+	hangmanWidth  = 200
+	hangmanHeight = 250
+)
+
+// DrawHangmanStage returns a PPM image of the hangman game after the given number of wrong guesses.
+// Stage 0 shows only the gallows and each stage up to 6 adds a body part: head, body, arms then legs.
+func DrawHangmanStage(stage int) *PPM {
+	stage = min(max(stage, 0), 6)
+
+	white := Pixel{255, 255, 255}
+	black := Pixel{0, 0, 0}
+
+	ppm := newPPM(hangmanWidth, hangmanHeight, "P3", 255)
+	ppm.DrawFilledRectangle(Point{0, 0}, hangmanWidth, hangmanHeight, white)
+
+	// Gallows: base, pole, beam and rope
+	ppm.DrawLine(Point{20, 230}, Point{120, 230}, black)
+	ppm.DrawLine(Point{50, 230}, Point{50, 20}, black)
+	ppm.DrawLine(Point{50, 20}, Point{140, 20}, black)
+	ppm.DrawLine(Point{140, 20}, Point{140, 50}, black)
+
+	// Body parts, in the order they are added
+	parts := []func(){
+		func() { ppm.DrawCircle(Point{140, 65}, 15, black) },
+		func() { ppm.DrawLine(Point{140, 80}, Point{140, 150}, black) },
+		func() { ppm.DrawLine(Point{140, 95}, Point{110, 125}, black) },
+		func() { ppm.DrawLine(Point{140, 95}, Point{170, 125}, black) },
+		func() { ppm.DrawLine(Point{140, 150}, Point{115, 195}, black) },
+		func() { ppm.DrawLine(Point{140, 150}, Point{165, 195}, black) },
+	}
+	for _, draw := range parts[:stage] {
+		draw()
+	}
+
+	return ppm
+}
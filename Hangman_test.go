@@ -0,0 +1,53 @@
+package Netpbm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// countInRect returns the number of pixels of the PPM image inside r equal to color.
+func countInRect(ppm *PPM, r Rect, color Pixel) int {
+	n := 0
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			if ppm.At(x, y) == color {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestDrawHangmanStage(t *testing.T) {
+	figure := Rect{100, 51, 80, 150}
+	gallows := Rect{0, 0, 60, 250}
+
+	empty := DrawHangmanStage(0)
+	if n := countInRect(empty, figure, black); n != 0 {
+		t.Errorf("stage 0 has %d black pixels in the figure area, want 0", n)
+	}
+	if n := countInRect(empty, gallows, black); n == 0 {
+		t.Error("stage 0 has no gallows")
+	}
+
+	previous := 0
+	for stage := 1; stage <= 6; stage++ {
+		n := countInRect(DrawHangmanStage(stage), figure, black)
+		if n <= previous {
+			t.Errorf("stage %d has %d black pixels in the figure area, want more than stage %d (%d)", stage, n, stage-1, previous)
+		}
+		previous = n
+	}
+
+	// The legs reach the bottom of the figure area
+	if n := countInRect(DrawHangmanStage(6), Rect{100, 180, 80, 21}, black); n == 0 {
+		t.Error("stage 6 has no legs")
+	}
+
+	if !reflect.DeepEqual(DrawHangmanStage(-3).data, empty.data) {
+		t.Error("negative stage is not clamped to 0")
+	}
+	if !reflect.DeepEqual(DrawHangmanStage(9).data, DrawHangmanStage(6).data) {
+		t.Error("stage above 6 is not clamped to 6")
+	}
+}
@@ -0,0 +1,98 @@
+package Netpbm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// LazyPGM gives random access to the samples of a binary (P5) PGM file without loading its pixel data.
+// The file stays open until Close is called, and every call to At reads a single byte from it.
+type LazyPGM struct {
+	file          *os.File
+	width, height int
+	max           uint
+	offset        int64
+}
+
+// OpenLazyPGM opens a P5 PGM file and reads its header. The pixel data is only read by At.
+func OpenLazyPGM(filename string) (*LazyPGM, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	lazy, err := newLazyPGM(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return lazy, nil
+}
+
+// newLazyPGM reads the header of the P5 PGM file and checks that the file holds all of its pixel data.
+func newLazyPGM(file *os.File) (*LazyPGM, error) {
+	// The header is read one byte at a time straight from the file, so the offset of the data is known exactly
+	magicNumber, err := readToken(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading magic number: %v", ErrInvalidHeader, err)
+	}
+	if magicNumber != "P5" {
+		return nil, fmt.Errorf("%w: lazy reading requires P5, got %s", ErrUnsupportedFormat, magicNumber)
+	}
+	width, height, err := readDimensions(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid dimensions: %v", ErrInvalidHeader, err)
+	}
+	maxValue, err := readToken(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading max value: %v", ErrInvalidHeader, err)
+	}
+	max, err := strconv.Atoi(maxValue)
+	if err != nil || max < 1 || max > 255 {
+		return nil, fmt.Errorf("%w: invalid max value: %s", ErrInvalidHeader, maxValue)
+	}
+
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if size := int64(width) * int64(height); info.Size()-offset < size {
+		return nil, fmt.Errorf("%w: expected %d bytes of pixel data, got %d", ErrTruncated, size, info.Size()-offset)
+	}
+
+	return &LazyPGM{file: file, width: width, height: height, max: uint(max), offset: offset}, nil
+}
+
+// Size returns the width and height of the image.
+func (lazy *LazyPGM) Size() (int, int) {
+	return lazy.width, lazy.height
+}
+
+// Max returns the max value of the image.
+func (lazy *LazyPGM) Max() uint {
+	return lazy.max
+}
+
+// At reads the value of the pixel at (x, y) from the file. It is safe to call from several goroutines.
+func (lazy *LazyPGM) At(x, y int) (uint8, error) {
+	if x < 0 || x >= lazy.width || y < 0 || y >= lazy.height {
+		return 0, fmt.Errorf("pixel (%d, %d) outside of the %dx%d image", x, y, lazy.width, lazy.height)
+	}
+
+	b := make([]byte, 1)
+	if _, err := lazy.file.ReadAt(b, lazy.offset+int64(y)*int64(lazy.width)+int64(x)); err != nil {
+		return 0, fmt.Errorf("error reading pixel (%d, %d): %v", x, y, err)
+	}
+	return b[0], nil
+}
+
+// Close closes the underlying file. At must not be called afterwards.
+func (lazy *LazyPGM) Close() error {
+	return lazy.file.Close()
+}
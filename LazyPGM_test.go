@@ -0,0 +1,56 @@
+package Netpbm
+
+import "testing"
+
+func TestLazyPGMCRLFHeader(t *testing.T) {
+	lazy, err := OpenLazyPGM("testdata/crlf.pgm")
+	if err != nil {
+		t.Fatalf("OpenLazyPGM: %v", err)
+	}
+	defer lazy.Close()
+
+	want := [][]uint8{{0x10, 0x20}, {0x30, 0x40}}
+	for y, row := range want {
+		for x, value := range row {
+			v, err := lazy.At(x, y)
+			if err != nil {
+				t.Fatalf("At(%d, %d): %v", x, y, err)
+			}
+			if v != value {
+				t.Errorf("At(%d, %d) = %d, want %d", x, y, v, value)
+			}
+		}
+	}
+}
+
+func TestLazyPGMMatchesReadPGM(t *testing.T) {
+	filename := writeP5(t, 37, 11)
+	pgm, err := ReadPGM(filename)
+	if err != nil {
+		t.Fatalf("ReadPGM: %v", err)
+	}
+	lazy, err := OpenLazyPGM(filename)
+	if err != nil {
+		t.Fatalf("OpenLazyPGM: %v", err)
+	}
+	defer lazy.Close()
+
+	if w, h := lazy.Size(); w != 37 || h != 11 {
+		t.Fatalf("Size() = %dx%d, want 37x11", w, h)
+	}
+	for _, p := range []Point{{0, 0}, {36, 0}, {0, 10}, {36, 10}, {18, 5}, {7, 3}, {29, 9}} {
+		v, err := lazy.At(p.X, p.Y)
+		if err != nil {
+			t.Fatalf("At(%d, %d): %v", p.X, p.Y, err)
+		}
+		if want := pgm.data[p.Y][p.X]; v != want {
+			t.Errorf("At(%d, %d) = %d, want %d", p.X, p.Y, v, want)
+		}
+	}
+
+	for _, p := range []Point{{-1, 0}, {37, 0}, {0, 11}} {
+		if _, err := lazy.At(p.X, p.Y); err == nil {
+			t.Errorf("At(%d, %d): expected an error", p.X, p.Y)
+		}
+	}
+}
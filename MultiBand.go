@@ -0,0 +1,44 @@
+package Netpbm
+
+import "fmt"
+
+// MultiBand is a stack of same-sized PGM images treated as the bands of a single image.
+type MultiBand []*PGM
+
+// BandCount returns the number of bands.
+func (mb MultiBand) BandCount() int {
+	return len(mb)
+}
+
+// At returns the value of the pixel at (x, y) in the given band.
+func (mb MultiBand) At(band, x, y int) uint8 {
+	return mb[band].At(x, y)
+}
+
+// ToPseudoColor builds a PPM image whose red, green and blue channels are taken from the given bands.
+func (mb MultiBand) ToPseudoColor(rBand, gBand, bBand int) (*PPM, error) {
+	bands := [3]int{rBand, gBand, bBand}
+	for _, band := range bands {
+		if band < 0 || band >= len(mb) || mb[band] == nil {
+			return nil, fmt.Errorf("invalid band: %d", band)
+		}
+	}
+
+	width, height := mb[rBand].Size()
+	var maxValue uint
+	for _, band := range bands {
+		w, h := mb[band].Size()
+		if w != width || h != height {
+			return nil, fmt.Errorf("size mismatch: band %d is %dx%d, expected %dx%d", band, w, h, width, height)
+		}
+		maxValue = max(maxValue, mb[band].max)
+	}
+
+	ppm := newPPM(width, height, "P3", maxValue)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			ppm.data[y][x] = Pixel{mb[rBand].data[y][x], mb[gBand].data[y][x], mb[bBand].data[y][x]}
+		}
+	}
+	return ppm, nil
+}
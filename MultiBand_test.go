@@ -0,0 +1,54 @@
+package Netpbm
+
+import "testing"
+
+// bandPGM returns a width×height PGM image whose pixel (x, y) is value(x, y).
+func bandPGM(width, height int, value func(x, y int) uint8) *PGM {
+	pgm := &PGM{data: make([][]uint8, height), width: width, height: height, magicNumber: "P2", max: 255}
+	for y := range pgm.data {
+		pgm.data[y] = make([]uint8, width)
+		for x := range pgm.data[y] {
+			pgm.data[y][x] = value(x, y)
+		}
+	}
+	return pgm
+}
+
+func TestMultiBandToPseudoColor(t *testing.T) {
+	mb := MultiBand{
+		bandPGM(4, 3, func(x, y int) uint8 { return uint8(10 * x) }),
+		bandPGM(4, 3, func(x, y int) uint8 { return uint8(20 * y) }),
+		bandPGM(4, 3, func(x, y int) uint8 { return uint8(x + y) }),
+	}
+	if n := mb.BandCount(); n != 3 {
+		t.Fatalf("BandCount() = %d, want 3", n)
+	}
+	if v := mb.At(1, 2, 2); v != 40 {
+		t.Errorf("At(1, 2, 2) = %d, want 40", v)
+	}
+
+	// Map band 2 to red, band 0 to green and band 1 to blue
+	ppm, err := mb.ToPseudoColor(2, 0, 1)
+	if err != nil {
+		t.Fatalf("ToPseudoColor: %v", err)
+	}
+	if ppm.width != 4 || ppm.height != 3 {
+		t.Fatalf("size = %dx%d, want 4x3", ppm.width, ppm.height)
+	}
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			want := Pixel{uint8(x + y), uint8(10 * x), uint8(20 * y)}
+			if got := ppm.data[y][x]; got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	if _, err := mb.ToPseudoColor(0, 1, 3); err == nil {
+		t.Error("expected an error for an invalid band")
+	}
+	mb = append(mb, bandPGM(2, 2, func(x, y int) uint8 { return 0 }))
+	if _, err := mb.ToPseudoColor(0, 1, 3); err == nil {
+		t.Error("expected an error for mismatched band sizes")
+	}
+}
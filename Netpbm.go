@@ -0,0 +1,144 @@
+package Netpbm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Image is implemented by the PBM, PGM and PPM types.
+type Image interface {
+	Size() (int, int)
+	Save(filename string) error
+	Invert()
+	Flip()
+	Flop()
+	ToImage() image.Image
+}
+
+var (
+	_ Image = (*PBM)(nil)
+	_ Image = (*PGM)(nil)
+	_ Image = (*PPM)(nil)
+)
+
+// Read reads a PBM, PGM or PPM image from the specified file, choosing the reader from its magic number.
+// The result can be type-asserted to *PBM, *PGM or *PPM.
+func Read(filename string) (Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := DetectFormat(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Return a nil interface rather than a typed nil pointer on error
+	var img Image
+	switch format {
+	case "PBM":
+		img, err = ReadPBM(filename)
+	case "PGM":
+		img, err = ReadPGM(filename)
+	default:
+		img, err = ReadPPM(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// DetectFormat reads the two-byte magic number from r and returns "PBM", "PGM" or "PPM".
+// Only the magic number is consumed from r.
+func DetectFormat(r io.Reader) (string, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return "", fmt.Errorf("%w: error reading magic number: %v", ErrInvalidHeader, err)
+	}
+
+	switch string(magic) {
+	case "P1", "P4":
+		return "PBM", nil
+	case "P2", "P5":
+		return "PGM", nil
+	case "P3", "P6":
+		return "PPM", nil
+	default:
+		return "", fmt.Errorf("%w: invalid magic number: %q", ErrUnsupportedFormat, magic)
+	}
+}
+
+// saveAtomic calls save on a temporary file in the directory of filename, then renames it to filename.
+// If save fails the temporary file is removed and any existing file is left untouched.
+// The result has the same mode as with save alone: the mode of the existing file, or 0666 minus the umask.
+func saveAtomic(filename string, save func(filename string) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	tmp.Close()
+
+	// CreateTemp uses mode 0600, so let save create the file again with the usual mode
+	if err := os.Remove(tmpName); err != nil {
+		return err
+	}
+	if err := save(tmpName); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if info, err := os.Stat(filename); err == nil {
+		if err := os.Chmod(tmpName, info.Mode().Perm()); err != nil {
+			os.Remove(tmpName)
+			return err
+		}
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// checksum returns the CRC32 (IEEE) of the dimensions, as two big-endian 32-bit values, followed by the pixel bytes.
+func checksum(width, height int, pixels []byte) uint32 {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(width))
+	binary.BigEndian.PutUint32(header[4:], uint32(height))
+	crc := crc32.ChecksumIEEE(header[:])
+	return crc32.Update(crc, crc32.IEEETable, pixels)
+}
+
+// Interpolation selects how images are sampled between pixel centers.
+type Interpolation int
+
+const (
+	// Nearest uses the value of the closest pixel.
+	Nearest Interpolation = iota
+	// Bilinear blends the four closest pixels by their distance.
+	Bilinear
+)
+
+// insideRounded reports whether (x, y) rounds to a pixel inside a width×height image.
+func insideRounded(x, y float64, width, height int) bool {
+	return x >= -0.5 && x < float64(width)-0.5 && y >= -0.5 && y < float64(height)-0.5
+}
+
+// bilinearWeights returns the four pixels surrounding (x, y), clamped to a width×height image,
+// and the horizontal and vertical weights of the second pixel of each pair.
+func bilinearWeights(x, y float64, width, height int) (x0, y0, x1, y1 int, fx, fy float64) {
+	x = math.Max(0, math.Min(x, float64(width-1)))
+	y = math.Max(0, math.Min(y, float64(height-1)))
+	x0, y0 = int(x), int(y)
+	x1, y1 = min(x0+1, width-1), min(y0+1, height-1)
+	return x0, y0, x1, y1, x - float64(x0), y - float64(y0)
+}
@@ -0,0 +1,277 @@
+package Netpbm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAtomicKeepsOriginalOnError(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "image.ppm")
+	original := []byte("P3\n1 1\n255\n1 2 3\n")
+	if err := os.WriteFile(filename, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	errWrite := errors.New("write failed")
+	err := saveAtomic(filename, func(name string) error {
+		// Write part of an image before failing, like an encoder interrupted mid-write
+		if err := os.WriteFile(name, []byte("P3\n1 1\n"), 0o666); err != nil {
+			return err
+		}
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("saveAtomic error = %v, want %v", err, errWrite)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(original) {
+		t.Errorf("original file changed to %q", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %d entries in %s", len(entries), dir)
+	}
+}
+
+func TestSaveAtomicMode(t *testing.T) {
+	dir := t.TempDir()
+	ppm := newPPM(2, 2, "P3", 255)
+
+	plain := filepath.Join(dir, "plain.ppm")
+	atomic := filepath.Join(dir, "atomic.ppm")
+	if err := ppm.Save(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ppm.SaveAtomic(atomic); err != nil {
+		t.Fatal(err)
+	}
+	plainInfo, err := os.Stat(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomicInfo, err := os.Stat(atomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plainInfo.Mode() != atomicInfo.Mode() {
+		t.Errorf("SaveAtomic mode = %v, want %v like Save", atomicInfo.Mode(), plainInfo.Mode())
+	}
+
+	// Replacing a file keeps its mode
+	if err := os.Chmod(atomic, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := ppm.SaveAtomic(atomic); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(atomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("SaveAtomic over a 0640 file: mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o640))
+	}
+}
+
+func TestReadSentinelErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		read    func(filename string) error
+		want    error
+	}{
+		{"PBM bad magic", "P7\n1 1\n1\n", readPBMErr, ErrUnsupportedFormat},
+		{"PBM bad dimensions", "P1\nx 1\n1\n", readPBMErr, ErrInvalidHeader},
+		{"PBM truncated P1", "P1\n2 2\n1 0 1\n", readPBMErr, ErrTruncated},
+		{"PBM truncated P4", "P4\n8 2\n\xff", readPBMErr, ErrTruncated},
+		{"PGM bad magic", "P3\n1 1\n255\n1\n", readPGMErr, ErrUnsupportedFormat},
+		{"PGM bad dimensions", "P2\n1\n", readPGMErr, ErrInvalidHeader},
+		{"PGM zero max", "P2\n1 1\n0\n0\n", readPGMErr, ErrInvalidHeader},
+		{"PGM truncated P2", "P2\n2 1\n255\n1\n", readPGMErr, ErrTruncated},
+		{"PGM truncated P5", "P5\n2 1\n255\n\x01", readPGMErr, ErrTruncated},
+		{"PPM bad magic", "P2\n1 1\n255\n1\n", readPPMErr, ErrUnsupportedFormat},
+		{"PPM binary", "P6\n1 1\n255\n\x01\x02\x03", readPPMErr, ErrUnsupportedFormat},
+		{"PPM bad max", "P3\n1 1\nx\n1 2 3\n", readPPMErr, ErrInvalidHeader},
+		{"PPM truncated", "P3\n1 1\n255\n1 2\n", readPPMErr, ErrTruncated},
+		{"Read bad magic", "XX\n", readErr, ErrUnsupportedFormat},
+		{"Read empty", "", readErr, ErrInvalidHeader},
+		{"Read binary PPM", "P6\n1 1\n255\n\x01\x02\x03", readErr, ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "image")
+			if err := os.WriteFile(filename, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.read(filename); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func readPBMErr(filename string) error {
+	_, err := ReadPBM(filename)
+	return err
+}
+
+func readPGMErr(filename string) error {
+	_, err := ReadPGM(filename)
+	return err
+}
+
+func readPPMErr(filename string) error {
+	_, err := ReadPPM(filename)
+	return err
+}
+
+func readErr(filename string) error {
+	_, err := Read(filename)
+	return err
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"P1\n2 2\n", "PBM"},
+		{"P4\n2 2\n", "PBM"},
+		{"P2\n2 2\n255\n", "PGM"},
+		{"P5\n2 2\n255\n", "PGM"},
+		{"P3\n2 2\n255\n", "PPM"},
+		{"P6\n2 2\n255\n", "PPM"},
+	}
+	for _, tt := range tests {
+		r := strings.NewReader(tt.header)
+		got, err := DetectFormat(r)
+		if err != nil {
+			t.Errorf("DetectFormat(%q): %v", tt.header, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+		// Only the magic number is consumed
+		if r.Len() != len(tt.header)-2 {
+			t.Errorf("DetectFormat(%q) consumed %d bytes, want 2", tt.header, len(tt.header)-r.Len())
+		}
+	}
+
+	for _, input := range []string{"\x89PNG\r\n", "P7\n", "P", ""} {
+		if _, err := DetectFormat(strings.NewReader(input)); err == nil {
+			t.Errorf("DetectFormat(%q): expected an error", input)
+		}
+	}
+}
+
+func TestReadConcreteTypes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"image.pbm": "P1\n2 1\n1 0\n",
+		"image.pgm": "P2\n2 1\n255\n10 20\n",
+		"image.ppm": "P3\n2 1\n255\n1 2 3 4 5 6\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	img, err := Read(filepath.Join(dir, "image.pbm"))
+	if pbm, ok := img.(*PBM); err != nil || !ok || !pbm.data[0][0] {
+		t.Errorf("Read P1 = %T, %v; want *PBM", img, err)
+	}
+	img, err = Read(filepath.Join(dir, "image.pgm"))
+	if pgm, ok := img.(*PGM); err != nil || !ok || pgm.data[0][1] != 20 {
+		t.Errorf("Read P2 = %T, %v; want *PGM", img, err)
+	}
+	img, err = Read(filepath.Join(dir, "image.ppm"))
+	if ppm, ok := img.(*PPM); err != nil || !ok || ppm.data[0][1] != (Pixel{4, 5, 6}) {
+		t.Errorf("Read P3 = %T, %v; want *PPM", img, err)
+	}
+
+	// Errors come back as a nil interface
+	if img, err := Read(filepath.Join(dir, "missing.pbm")); err == nil || img != nil {
+		t.Errorf("Read of a missing file = %v, %v; want nil and an error", img, err)
+	}
+}
+
+func TestImageInterface(t *testing.T) {
+	pbm := newPBM(2, 1, "P1")
+	pbm.data[0][0] = true
+	pgm := &PGM{data: [][]uint8{{10, 200}}, width: 2, height: 1, magicNumber: "P2", max: 255}
+	ppm := newPPM(2, 1, "P3", 255)
+	ppm.data[0][0] = Pixel{0, 100, 255}
+
+	dir := t.TempDir()
+	images := []Image{pbm, pgm, ppm}
+	names := []string{"out.pbm", "out.pgm", "out.ppm"}
+	for i, img := range images {
+		if w, h := img.Size(); w != 2 || h != 1 {
+			t.Errorf("%T size = %dx%d, want 2x1", img, w, h)
+		}
+		img.Invert()
+		if err := img.Save(filepath.Join(dir, names[i])); err != nil {
+			t.Errorf("%T Save: %v", img, err)
+		}
+	}
+
+	gotPBM, err := ReadPBM(filepath.Join(dir, "out.pbm"))
+	if err != nil || gotPBM.data[0][0] || !gotPBM.data[0][1] {
+		t.Errorf("saved PBM = %v, %v; want inverted pixels", gotPBM, err)
+	}
+	gotPGM, err := ReadPGM(filepath.Join(dir, "out.pgm"))
+	if err != nil || gotPGM.data[0][0] != 245 || gotPGM.data[0][1] != 55 {
+		t.Errorf("saved PGM = %v, %v; want inverted pixels", gotPGM, err)
+	}
+	gotPPM, err := ReadPPM(filepath.Join(dir, "out.ppm"))
+	if err != nil || gotPPM.data[0][0] != (Pixel{255, 155, 0}) || gotPPM.data[0][1] != white {
+		t.Errorf("saved PPM = %v, %v; want inverted pixels", gotPPM, err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	ppmA, ppmB := patternPPM(6, 4), patternPPM(6, 4)
+	if ppmA.Checksum() != ppmB.Checksum() {
+		t.Error("identical PPM images have different checksums")
+	}
+	ppmB.data[3][5].G++
+	if ppmA.Checksum() == ppmB.Checksum() {
+		t.Error("changing one PPM pixel kept the checksum")
+	}
+
+	pgmA, pgmB := noisePGM(6, 4), noisePGM(6, 4)
+	if pgmA.Checksum() != pgmB.Checksum() {
+		t.Error("identical PGM images have different checksums")
+	}
+	pgmB.data[0][0]++
+	if pgmA.Checksum() == pgmB.Checksum() {
+		t.Error("changing one PGM pixel kept the checksum")
+	}
+
+	pbmA, pbmB := newPBM(6, 4, "P1"), newPBM(6, 4, "P1")
+	if pbmA.Checksum() != pbmB.Checksum() {
+		t.Error("identical PBM images have different checksums")
+	}
+	pbmB.data[2][3] = true
+	if pbmA.Checksum() == pbmB.Checksum() {
+		t.Error("changing one PBM pixel kept the checksum")
+	}
+
+	// The dimensions are part of the checksum
+	if newPBM(6, 4, "P1").Checksum() == newPBM(4, 6, "P1").Checksum() {
+		t.Error("blank bitmaps of different shapes have the same checksum")
+	}
+}
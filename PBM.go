@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"image"
+	"image/color"
 	"io"
+	"math"
 	"os"
 	"strings"
 )
@@ -27,24 +30,19 @@ func ReadPBM(filename string) (*PBM, error) {
 	reader := bufio.NewReader(file)
 
 	// Read magic number
-	magicNumber, err := reader.ReadString('\n')
+	magicNumber, err := readLine(reader)
 	if err != nil {
-		return nil, fmt.Errorf("error reading magic number: %v", err)
+		return nil, fmt.Errorf("%w: error reading magic number: %v", ErrInvalidHeader, err)
 	}
 	magicNumber = strings.TrimSpace(magicNumber)
 	if magicNumber != "P1" && magicNumber != "P4" {
-		return nil, fmt.Errorf("invalid magic number: %s", magicNumber)
+		return nil, fmt.Errorf("%w: invalid magic number: %s", ErrUnsupportedFormat, magicNumber)
 	}
 
 	// Read dimensions
-	dimensions, err := reader.ReadString('\n')
+	width, height, err := readDimensions(reader)
 	if err != nil {
-		return nil, fmt.Errorf("error reading dimensions: %v", err)
-	}
-	var width, height int
-	_, err = fmt.Sscanf(strings.TrimSpace(dimensions), "%d %d", &width, &height)
-	if err != nil {
-		return nil, fmt.Errorf("invalid dimensions: %v", err)
+		return nil, fmt.Errorf("%w: invalid dimensions: %v", ErrInvalidHeader, err)
 	}
 
 	data := make([][]bool, height)
@@ -55,17 +53,22 @@ func ReadPBM(filename string) (*PBM, error) {
 
 	if magicNumber == "P1" {
 		// Read P1 format (ASCII)
-		for y := 0; y < height; y++ {
-			line, err := reader.ReadString('\n')
+		// Pixels may be separated by whitespace or packed together, so read them one digit at a time
+		for i := 0; i < width*height; {
+			c, err := reader.ReadByte()
 			if err != nil {
-				return nil, fmt.Errorf("error reading data at row %d: %v", y, err)
-			}
-			fields := strings.Fields(line)
-			for x, field := range fields {
-				if x >= width {
-					return nil, fmt.Errorf("index out of range at row %d", y)
+				if err == io.EOF {
+					return nil, fmt.Errorf("%w at row %d", ErrTruncated, i/width)
 				}
-				data[y][x] = field == "1"
+				return nil, fmt.Errorf("error reading data at row %d: %v", i/width, err)
+			}
+			switch c {
+			case '0', '1':
+				data[i/width][i%width] = c == '1'
+				i++
+			case ' ', '\t', '\n', '\r', '\v', '\f':
+			default:
+				return nil, fmt.Errorf("invalid pixel value %q at row %d", c, i/width)
 			}
 		}
 
@@ -79,36 +82,18 @@ func ReadPBM(filename string) (*PBM, error) {
 		for y := 0; y < height; y++ {
 			// Read a row of bytes from the input file.
 			row := make([]byte, expectedBytesPerRow)
-			n, err := reader.Read(row)
+			n, err := io.ReadFull(reader, row)
 			if err != nil {
 				// Handle the case where unexpected end of file occurs
-				if err == io.EOF {
-					return nil, fmt.Errorf("unexpected end of file at row %d", y)
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					return nil, fmt.Errorf("%w at row %d, expected %d bytes, got %d", ErrTruncated, y, expectedBytesPerRow, n)
 				}
 				// Handle other errors while reading pixel data.
 				return nil, fmt.Errorf("error reading pixel data at row %d: %v", y, err)
 			}
 
-			// Check if the actual number of bytes read matches the expected number.
-			if n < expectedBytesPerRow {
-				return nil, fmt.Errorf("unexpected end of file at row %d, expected %d bytes, got %d", y, expectedBytesPerRow, n)
-			}
-
-			// Iterate over each pixel in the row
-			for x := 0; x < width; x++ {
-				// Calculate the index of the byte containing the current pixel
-				byteIndex := x / 8
-
-				// Calculate the bit index within the byte for the current pixel
-				bitIndex := 7 - (x % 8)
-
-				// Extract the bit value from the byte using a bitwise AND operation
-				// Shift the decimal value to the right by the bit index, then perform a bitwise AND with 1
-				bitValue := (int(row[byteIndex]) >> bitIndex) & 1
-
-				// Set the corresponding pixel in the image data based on the extracted bit value
-				data[y][x] = bitValue != 0
-			}
+			// Extract the pixels from the bits of the row
+			unpackRow(row, data[y])
 		}
 	}
 
@@ -130,6 +115,19 @@ func (pbm *PBM) Set(x, y int, value bool) {
 	pbm.data[y][x] = value
 }
 
+// Validate checks that the pixel data matches the width and height of the image.
+func (pbm *PBM) Validate() error {
+	if len(pbm.data) != pbm.height {
+		return fmt.Errorf("invalid data: expected %d rows, got %d", pbm.height, len(pbm.data))
+	}
+	for y, row := range pbm.data {
+		if len(row) != pbm.width {
+			return fmt.Errorf("invalid data: expected %d pixels at row %d, got %d", pbm.width, y, len(row))
+		}
+	}
+	return nil
+}
+
 // Save saves the PBM image to the specified file.
 func (pbm *PBM) Save(filename string) error {
 	if pbm == nil {
@@ -155,41 +153,45 @@ func (pbm *PBM) Save(filename string) error {
 	}
 }
 
-// saveP1 saves the PBM image in P1 format (ASCII)
+// SaveAs saves the PBM image to the specified file in the given format, "P1" or "P4", without changing its magic number.
+func (pbm *PBM) SaveAs(filename, magic string) error {
+	if pbm == nil {
+		return errors.New("cannot save a nil PBM")
+	}
+	if magic != "P1" && magic != "P4" {
+		return fmt.Errorf("unsupported magic number: %s", magic)
+	}
+	converted := *pbm
+	converted.magicNumber = magic
+	return converted.Save(filename)
+}
+
+// saveP1 saves the PBM image in P1 format (ASCII), wrapping lines at 70 characters
 func (pbm *PBM) saveP1(file *os.File) error {
+	writer := bufio.NewWriter(file)
+	aw := &asciiWriter{w: writer, maxWidth: maxLineWidth}
 	for i := 0; i < pbm.height; i++ {
 		for j := 0; j < pbm.width; j++ {
 			// Write the binary value of the pixel
 			if pbm.data[i][j] {
-				fmt.Fprint(file, "1")
+				aw.sample(1)
 			} else {
-				fmt.Fprint(file, "0")
-			}
-
-			// Add a space after each pixel, except the last one in a row
-			if j < pbm.width-1 {
-				fmt.Fprint(file, " ")
+				aw.sample(0)
 			}
 		}
 		// Add a newline after each row
-		fmt.Fprintln(file)
+		aw.newline()
 	}
-	return nil
+	if aw.err != nil {
+		return fmt.Errorf("error writing pixel data: %v", aw.err)
+	}
+	return writer.Flush()
 }
 
 // saveP4 saves the PBM image in P4 format (binary)
 func (pbm *PBM) saveP4(file *os.File) error {
-	expectedBytesPerRow := (pbm.width + 7) / 8
 	for y := 0; y < pbm.height; y++ {
-		row := make([]byte, expectedBytesPerRow)
-		for x := 0; x < pbm.width; x++ {
-			byteIndex := x / 8
-			bitIndex := 7 - (x % 8)
-			if pbm.data[y][x] {
-				row[byteIndex] |= 1 << bitIndex
-			}
-		}
-		_, err := file.Write(row)
+		_, err := file.Write(packRow(pbm.data[y]))
 		if err != nil {
 			return fmt.Errorf("error writing pixel data at row %d: %v", y, err)
 		}
@@ -197,6 +199,59 @@ func (pbm *PBM) saveP4(file *os.File) error {
 	return nil
 }
 
+// packRow packs a row of pixels into P4 bytes, most significant bit first, with the padding bits of the last byte set to 0.
+func packRow(pixels []bool) []byte {
+	row := make([]byte, (len(pixels)+7)/8)
+	for x, v := range pixels {
+		if v {
+			row[x/8] |= 1 << (7 - x%8)
+		}
+	}
+	return row
+}
+
+// unpackRow sets pixels from the bits of a P4 row, most significant bit first. It is the inverse of packRow.
+func unpackRow(row []byte, pixels []bool) {
+	for x := range pixels {
+		pixels[x] = (int(row[x/8])>>(7-x%8))&1 != 0
+	}
+}
+
+// RowPadding returns the number of padding bits (0 to 7) at the end of each row of the PBM image in P4 format.
+func (pbm *PBM) RowPadding() int {
+	return (8 - pbm.width%8) % 8
+}
+
+// VerifyPacking checks that every row of the PBM image round-trips through P4 packing without loss:
+// each row must have exactly width pixels, and unpacking its packed bytes the way ReadPBM does must give back
+// the same pixels with all padding bits set to 0.
+func (pbm *PBM) VerifyPacking() error {
+	if len(pbm.data) != pbm.height {
+		return fmt.Errorf("expected %d rows, got %d", pbm.height, len(pbm.data))
+	}
+	padding := pbm.RowPadding()
+	for y, pixels := range pbm.data {
+		if len(pixels) != pbm.width {
+			return fmt.Errorf("row %d: expected %d pixels, got %d", y, pbm.width, len(pixels))
+		}
+		row := packRow(pixels)
+		if len(row) != (pbm.width+7)/8 {
+			return fmt.Errorf("row %d: expected %d bytes, got %d", y, (pbm.width+7)/8, len(row))
+		}
+		unpacked := make([]bool, pbm.width)
+		unpackRow(row, unpacked)
+		for x, v := range unpacked {
+			if v != pixels[x] {
+				return fmt.Errorf("row %d: pixel %d does not round-trip", y, x)
+			}
+		}
+		if len(row) > 0 && row[len(row)-1]&(1<<padding-1) != 0 {
+			return fmt.Errorf("row %d: padding bits are not zero", y)
+		}
+	}
+	return nil
+}
+
 // Invert inverts the colors of the PBM image.
 func (pbm *PBM) Invert() {
 	for i := 0; i < pbm.height; i++ {
@@ -222,7 +277,282 @@ func (pbm *PBM) Flop() {
 	}
 }
 
+// Rotate90CW rotates the PBM image 90° clockwise.
+func (pbm *PBM) Rotate90CW() {
+	newData := make([][]bool, pbm.width)
+	for i := 0; i < pbm.width; i++ {
+		newData[i] = make([]bool, pbm.height)
+		for j := 0; j < pbm.height; j++ {
+			newData[i][j] = pbm.data[pbm.height-j-1][i]
+		}
+	}
+
+	pbm.data = newData
+	pbm.width, pbm.height = pbm.height, pbm.width
+}
+
 // SetMagicNumber sets the magic number of the PBM image.
 func (pbm *PBM) SetMagicNumber(magicNumber string) {
 	pbm.magicNumber = magicNumber
 }
+
+// CountIf returns the number of pixels of the PBM image that satisfy pred.
+func (pbm *PBM) CountIf(pred func(bool) bool) int {
+	count := 0
+	for i := 0; i < pbm.height; i++ {
+		for j := 0; j < pbm.width; j++ {
+			if pred(pbm.data[i][j]) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// IsUniform reports whether all pixels of the PBM image have the same value and, if so, returns that value.
+func (pbm *PBM) IsUniform() (bool, bool) {
+	if pbm.width <= 0 || pbm.height <= 0 {
+		return false, false
+	}
+	first := pbm.data[0][0]
+	for i := 0; i < pbm.height; i++ {
+		for j := 0; j < pbm.width; j++ {
+			if pbm.data[i][j] != first {
+				return false, false
+			}
+		}
+	}
+	return true, first
+}
+
+// newPBM returns a PBM image with all pixels cleared and the given dimensions and magic number.
+func newPBM(width, height int, magicNumber string) *PBM {
+	pbm := &PBM{
+		data:        make([][]bool, height),
+		width:       width,
+		height:      height,
+		magicNumber: magicNumber,
+	}
+	for i := range pbm.data {
+		pbm.data[i] = make([]bool, width)
+	}
+	return pbm
+}
+
+// DistanceTransform returns, for each pixel, the Euclidean distance to the nearest true pixel.
+// True pixels have distance 0, and every distance is +Inf if the image has no true pixel.
+func (pbm *PBM) DistanceTransform() [][]float64 {
+	inf := math.Inf(1)
+	dist := make([][]float64, pbm.height)
+	for i := range dist {
+		dist[i] = make([]float64, pbm.width)
+		for j := range dist[i] {
+			if !pbm.data[i][j] {
+				dist[i][j] = inf
+			}
+		}
+	}
+
+	// Squared distances along columns, then along rows, then take the square root
+	column := make([]float64, pbm.height)
+	for j := 0; j < pbm.width; j++ {
+		for i := range column {
+			column[i] = dist[i][j]
+		}
+		column = squaredDistance1D(column)
+		for i := range column {
+			dist[i][j] = column[i]
+		}
+	}
+	for i := range dist {
+		dist[i] = squaredDistance1D(dist[i])
+		for j := range dist[i] {
+			dist[i][j] = math.Sqrt(dist[i][j])
+		}
+	}
+	return dist
+}
+
+// squaredDistance1D computes the 1D squared Euclidean distance transform of f using the lower envelope of parabolas
+// (Felzenszwalb and Huttenlocher). f holds 0 for sites, +Inf for empty cells, or squared distances from a previous pass.
+func squaredDistance1D(f []float64) []float64 {
+	n := len(f)
+	d := make([]float64, n)
+	v := make([]int, 0, n)       // positions of the parabolas in the lower envelope
+	z := make([]float64, 0, n+1) // boundaries between the parabolas
+	for q := 0; q < n; q++ {
+		if math.IsInf(f[q], 1) {
+			continue
+		}
+		for {
+			if len(v) == 0 {
+				v = append(v, q)
+				z = append(z[:0], math.Inf(-1), math.Inf(1))
+				break
+			}
+			p := v[len(v)-1]
+			s := ((f[q] + float64(q*q)) - (f[p] + float64(p*p))) / float64(2*q-2*p)
+			if s <= z[len(z)-2] {
+				v = v[:len(v)-1]
+				z = z[:len(z)-1]
+				continue
+			}
+			z[len(z)-1] = s
+			v = append(v, q)
+			z = append(z, math.Inf(1))
+			break
+		}
+	}
+
+	if len(v) == 0 {
+		for q := range d {
+			d[q] = math.Inf(1)
+		}
+		return d
+	}
+
+	k := 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		dq := float64(q - v[k])
+		d[q] = dq*dq + f[v[k]]
+	}
+	return d
+}
+
+// Thin reduces the true regions of the PBM image to 1px wide skeletons using the Zhang-Suen algorithm.
+func (pbm *PBM) Thin() {
+	at := func(x, y int) bool {
+		return x >= 0 && x < pbm.width && y >= 0 && y < pbm.height && pbm.data[y][x]
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for step := 0; step < 2; step++ {
+			var remove []Point
+			for y := 0; y < pbm.height; y++ {
+				for x := 0; x < pbm.width; x++ {
+					if !pbm.data[y][x] {
+						continue
+					}
+
+					// Neighbors P2 to P9, clockwise from the pixel above
+					n := [8]bool{at(x, y-1), at(x+1, y-1), at(x+1, y), at(x+1, y+1), at(x, y+1), at(x-1, y+1), at(x-1, y), at(x-1, y-1)}
+					count, transitions := 0, 0
+					for k := 0; k < 8; k++ {
+						if n[k] {
+							count++
+						}
+						if !n[k] && n[(k+1)%8] {
+							transitions++
+						}
+					}
+					if count < 2 || count > 6 || transitions != 1 {
+						continue
+					}
+
+					if step == 0 && !(n[0] && n[2] && n[4]) && !(n[2] && n[4] && n[6]) ||
+						step == 1 && !(n[0] && n[2] && n[6]) && !(n[0] && n[4] && n[6]) {
+						remove = append(remove, Point{x, y})
+					}
+				}
+			}
+
+			for _, p := range remove {
+				pbm.data[p.Y][p.X] = false
+			}
+			changed = changed || len(remove) > 0
+		}
+	}
+}
+
+// Reset sets every pixel of the PBM image to false, keeping its dimensions.
+func (pbm *PBM) Reset() {
+	for i := range pbm.data {
+		clear(pbm.data[i])
+	}
+}
+
+// ToImage converts the PBM image to the Go image.Image interface. True pixels are black and false pixels are white.
+func (pbm *PBM) ToImage() image.Image {
+	img := image.NewGray(image.Rect(0, 0, pbm.width, pbm.height))
+	for i := 0; i < pbm.height; i++ {
+		for j := 0; j < pbm.width; j++ {
+			if pbm.data[i][j] {
+				img.SetGray(j, i, color.Gray{0})
+			} else {
+				img.SetGray(j, i, color.Gray{255})
+			}
+		}
+	}
+	return img
+}
+
+// ToPGM converts the PBM image to a PGM with a max value of 255. True pixels become black and false pixels white.
+func (pbm *PBM) ToPGM() *PGM {
+	pgm := &PGM{
+		data:        make([][]uint8, pbm.height),
+		width:       pbm.width,
+		height:      pbm.height,
+		magicNumber: "P2",
+		max:         255,
+	}
+	for i := 0; i < pbm.height; i++ {
+		pgm.data[i] = make([]uint8, pbm.width)
+		for j := 0; j < pbm.width; j++ {
+			if !pbm.data[i][j] {
+				pgm.data[i][j] = 255
+			}
+		}
+	}
+	return pgm
+}
+
+// SaveAtomic saves the PBM image like Save, but writes to a temporary file first and renames it over filename,
+// so an error while writing never leaves a partially written file behind.
+func (pbm *PBM) SaveAtomic(filename string) error {
+	return saveAtomic(filename, pbm.Save)
+}
+
+// Coverage returns the fraction of true (ink) pixels of the PBM image, in [0, 1].
+func (pbm *PBM) Coverage() float64 {
+	if pbm.width <= 0 || pbm.height <= 0 {
+		return 0
+	}
+	ink := pbm.CountIf(func(v bool) bool { return v })
+	return float64(ink) / float64(pbm.width*pbm.height)
+}
+
+// Scale enlarges the PBM image by an integer factor, turning each pixel into a factor×factor block.
+func (pbm *PBM) Scale(factor int) {
+	if factor <= 1 {
+		return
+	}
+	newData := make([][]bool, pbm.height*factor)
+	for i := range newData {
+		newData[i] = make([]bool, pbm.width*factor)
+		for j := range newData[i] {
+			newData[i][j] = pbm.data[i/factor][j/factor]
+		}
+	}
+
+	pbm.data = newData
+	pbm.width, pbm.height = pbm.width*factor, pbm.height*factor
+}
+
+// Checksum returns a CRC32 of the dimensions and pixels of the PBM image, with one byte (0 or 1) per pixel in row-major order.
+func (pbm *PBM) Checksum() uint32 {
+	pixels := make([]byte, 0, pbm.width*pbm.height)
+	for i := 0; i < pbm.height; i++ {
+		for j := 0; j < pbm.width; j++ {
+			if pbm.data[i][j] {
+				pixels = append(pixels, 1)
+			} else {
+				pixels = append(pixels, 0)
+			}
+		}
+	}
+	return checksum(pbm.width, pbm.height, pixels)
+}
@@ -0,0 +1,285 @@
+package Netpbm
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPBMCRLFHeader(t *testing.T) {
+	pbm, err := ReadPBM("testdata/crlf.pbm")
+	if err != nil {
+		t.Fatalf("ReadPBM: %v", err)
+	}
+
+	want := [][]bool{
+		{true, true, true, true, true, true, true, true},
+		{true, false, false, false, false, false, false, true},
+	}
+	if !reflect.DeepEqual(pbm.data, want) {
+		t.Errorf("data = %v, want %v", pbm.data, want)
+	}
+}
+
+func TestPBMValidateJagged(t *testing.T) {
+	pbm := &PBM{data: [][]bool{{true, false, true}, {true, false}}, width: 3, height: 2, magicNumber: "P1"}
+	err := pbm.Validate()
+	if err == nil {
+		t.Fatal("Validate succeeded on a jagged grid")
+	}
+	if want := "invalid data: expected 3 pixels at row 1, got 2"; err.Error() != want {
+		t.Errorf("Validate() = %q, want %q", err, want)
+	}
+
+	pbm.data = pbm.data[:1]
+	if err := pbm.Validate(); err == nil || err.Error() != "invalid data: expected 2 rows, got 1" {
+		t.Errorf("Validate() with a missing row = %v", err)
+	}
+}
+
+func TestReadPBMWhitespaceDimensions(t *testing.T) {
+	for _, header := range []string{"P1\n3\t2\n", "P1\n3\n2\n", "P1\n  3 \t\n\n 2\n"} {
+		filename := filepath.Join(t.TempDir(), "image.pbm")
+		if err := os.WriteFile(filename, []byte(header+"1 0 1\n0 1 0\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		pbm, err := ReadPBM(filename)
+		if err != nil {
+			t.Fatalf("ReadPBM(%q): %v", header, err)
+		}
+		if w, h := pbm.Size(); w != 3 || h != 2 {
+			t.Errorf("ReadPBM(%q) size = %d, %d, want 3, 2", header, w, h)
+		}
+	}
+}
+
+func TestPBMCountIf(t *testing.T) {
+	pbm := &PBM{data: [][]bool{{true, false, true}, {false, false, true}}, width: 3, height: 2, magicNumber: "P1"}
+	if got := pbm.CountIf(func(v bool) bool { return v }); got != 3 {
+		t.Errorf("CountIf(true) = %d, want 3", got)
+	}
+	if got := pbm.CountIf(func(v bool) bool { return !v }); got != 3 {
+		t.Errorf("CountIf(false) = %d, want 3", got)
+	}
+}
+
+func TestPBMIsUniform(t *testing.T) {
+	pbm := newPBM(3, 2, "P1")
+	if ok, value := pbm.IsUniform(); !ok || value {
+		t.Errorf("IsUniform() of a blank bitmap = %v, %v, want true, false", ok, value)
+	}
+	pbm.Set(2, 1, true)
+	if ok, _ := pbm.IsUniform(); ok {
+		t.Error("IsUniform() with one set pixel = true")
+	}
+}
+
+func TestReadPBMP1Layouts(t *testing.T) {
+	spaced, err := ReadPBM("testdata/p1_spaced.pbm")
+	if err != nil {
+		t.Fatalf("ReadPBM spaced: %v", err)
+	}
+	packed, err := ReadPBM("testdata/p1_packed.pbm")
+	if err != nil {
+		t.Fatalf("ReadPBM packed: %v", err)
+	}
+
+	want := [][]bool{
+		{true, false, true, true, false},
+		{false, true, false, false, true},
+		{true, true, true, false, false},
+	}
+	if !reflect.DeepEqual(spaced.data, want) {
+		t.Errorf("spaced data = %v, want %v", spaced.data, want)
+	}
+	if !reflect.DeepEqual(packed.data, spaced.data) {
+		t.Errorf("packed data = %v, want the same as spaced %v", packed.data, spaced.data)
+	}
+}
+
+func TestDistanceTransformSinglePixel(t *testing.T) {
+	pbm := newPBM(9, 7, "P1")
+	pbm.data[3][4] = true
+	dist := pbm.DistanceTransform()
+
+	for y := range dist {
+		for x := range dist[y] {
+			want := math.Hypot(float64(x-4), float64(y-3))
+			if math.Abs(dist[y][x]-want) > 1e-9 {
+				t.Errorf("distance at (%d, %d) = %v, want %v", x, y, dist[y][x], want)
+			}
+		}
+	}
+	// Distances increase moving away from the pixel in every direction
+	for d := 1; d <= 4; d++ {
+		if dist[3][4+d] <= dist[3][4+d-1] || dist[3][4-d] <= dist[3][4-d+1] {
+			t.Errorf("distance does not increase horizontally at step %d", d)
+		}
+	}
+	for d := 1; d <= 3; d++ {
+		if dist[3+d][4] <= dist[3+d-1][4] || dist[3-d][4] <= dist[3-d+1][4] {
+			t.Errorf("distance does not increase vertically at step %d", d)
+		}
+	}
+}
+
+func TestThinBar(t *testing.T) {
+	pbm := newPBM(30, 11, "P1")
+	for y := 3; y <= 7; y++ {
+		for x := 3; x <= 26; x++ {
+			pbm.data[y][x] = true
+		}
+	}
+	pbm.Thin()
+
+	// Away from the ends every column keeps a single pixel near the middle row
+	for x := 8; x <= 21; x++ {
+		var rows []int
+		for y := 0; y < pbm.height; y++ {
+			if pbm.data[y][x] {
+				rows = append(rows, y)
+			}
+		}
+		if len(rows) != 1 || rows[0] < 4 || rows[0] > 6 {
+			t.Errorf("column %d has pixels at rows %v, want a single pixel near row 5", x, rows)
+		}
+	}
+}
+
+func TestPBMReset(t *testing.T) {
+	pbm := newPBM(5, 3, "P1")
+	pbm.data[0][0], pbm.data[2][4] = true, true
+	pbm.Reset()
+
+	if pbm.width != 5 || pbm.height != 3 || len(pbm.data) != 3 || len(pbm.data[0]) != 5 {
+		t.Fatalf("size changed to %dx%d", pbm.width, pbm.height)
+	}
+	if n := pbm.CountIf(func(v bool) bool { return v }); n != 0 {
+		t.Errorf("%d pixels still set", n)
+	}
+}
+
+func TestPBMCoverage(t *testing.T) {
+	pbm := newPBM(6, 4, "P1")
+	if c := pbm.Coverage(); c != 0 {
+		t.Errorf("Coverage() of an empty bitmap = %v, want 0", c)
+	}
+	for y := 0; y < pbm.height; y++ {
+		for x := 0; x < pbm.width/2; x++ {
+			pbm.data[y][x] = true
+		}
+	}
+	if c := pbm.Coverage(); c != 0.5 {
+		t.Errorf("Coverage() = %v, want 0.5", c)
+	}
+}
+
+func TestPBMRotate90CW(t *testing.T) {
+	pbm := newPBM(3, 2, "P1")
+	pbm.data[0][2] = true // top-right corner
+	pbm.data[1][0] = true // bottom-left corner
+	pbm.Rotate90CW()
+
+	if pbm.width != 2 || pbm.height != 3 || len(pbm.data) != 3 || len(pbm.data[0]) != 2 {
+		t.Fatalf("size = %dx%d, want 2x3", pbm.width, pbm.height)
+	}
+	// Pixel (x, y) moves to (height-1-y, x)
+	want := [][]bool{
+		{true, false},
+		{false, false},
+		{false, true},
+	}
+	if !reflect.DeepEqual(pbm.data, want) {
+		t.Errorf("data = %v, want %v", pbm.data, want)
+	}
+}
+
+func TestPBMScale(t *testing.T) {
+	pbm := newPBM(3, 2, "P1")
+	pbm.data[0][1] = true
+	pbm.data[1][2] = true
+	original := [][]bool{append([]bool(nil), pbm.data[0]...), append([]bool(nil), pbm.data[1]...)}
+	pbm.Scale(3)
+
+	if pbm.width != 9 || pbm.height != 6 || len(pbm.data) != 6 || len(pbm.data[0]) != 9 {
+		t.Fatalf("size = %dx%d, want 9x6", pbm.width, pbm.height)
+	}
+	// Every original pixel becomes a 3×3 block of the same value
+	for y := 0; y < pbm.height; y++ {
+		for x := 0; x < pbm.width; x++ {
+			if want := original[y/3][x/3]; pbm.data[y][x] != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, pbm.data[y][x], want)
+			}
+		}
+	}
+}
+
+func TestPBMSaveAs(t *testing.T) {
+	pgm := &PGM{data: [][]uint8{{0, 200, 30, 255, 10, 90, 180, 0, 250, 5}, {255, 0, 255, 0, 255, 0, 255, 0, 255, 0}}, width: 10, height: 2, magicNumber: "P2", max: 255}
+	pbm := pgm.ToPBM()
+
+	dir := t.TempDir()
+	for _, magic := range []string{"P1", "P4"} {
+		filename := filepath.Join(dir, magic+".pbm")
+		if err := pbm.SaveAs(filename, magic); err != nil {
+			t.Fatalf("SaveAs(%s): %v", magic, err)
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content[:2]) != magic {
+			t.Errorf("file starts with %q, want %q", content[:2], magic)
+		}
+
+		got, err := ReadPBM(filename)
+		if err != nil {
+			t.Fatalf("ReadPBM(%s): %v", magic, err)
+		}
+		if got.width != pbm.width || got.height != pbm.height || !reflect.DeepEqual(got.data, pbm.data) {
+			t.Errorf("%s: read back %v, want %v", magic, got.data, pbm.data)
+		}
+	}
+	if pbm.magicNumber != "P1" {
+		t.Errorf("SaveAs changed the magic number to %q", pbm.magicNumber)
+	}
+	if err := pbm.SaveAs(filepath.Join(dir, "bad.pbm"), "P2"); err == nil {
+		t.Error("expected an error for a non-PBM magic number")
+	}
+}
+
+func TestPBMPackingWidth10(t *testing.T) {
+	pbm := newPBM(10, 3, "P4")
+	for y := 0; y < pbm.height; y++ {
+		for x := 0; x < pbm.width; x++ {
+			pbm.data[y][x] = (x+y)%3 == 0 || x == 9
+		}
+	}
+
+	if p := pbm.RowPadding(); p != 6 {
+		t.Errorf("RowPadding() = %d, want 6", p)
+	}
+	if err := pbm.VerifyPacking(); err != nil {
+		t.Errorf("VerifyPacking: %v", err)
+	}
+
+	// Saving as P4 and reading back is lossless
+	filename := filepath.Join(t.TempDir(), "packed.pbm")
+	if err := pbm.Save(filename); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := ReadPBM(filename)
+	if err != nil {
+		t.Fatalf("ReadPBM: %v", err)
+	}
+	if !reflect.DeepEqual(got.data, pbm.data) {
+		t.Errorf("read back %v, want %v", got.data, pbm.data)
+	}
+
+	pbm.data[1] = pbm.data[1][:9]
+	if err := pbm.VerifyPacking(); err == nil {
+		t.Error("expected an error for a short row")
+	}
+}
@@ -3,8 +3,13 @@ package Netpbm
 import (
 	"bufio"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
+	"math"
 	"os"
+	"runtime"
 	"strings"
 )
 
@@ -22,116 +27,135 @@ type PGM struct {
 func ReadPGM(filename string) (*PGM, error) {
 	// ReadPGM: This function reads a PGM file from a given filename. It returns a PGM struct pointer and an error if any occurs during the read process.
 
-	file, err := os.Open(filename)
+	pgm := &PGM{}
+	err := ReadPGMInto(filename, pgm)
 	if err != nil {
 		return nil, err
 	}
 
+	return pgm, nil
+}
+
+// Function: ReadPGMInto
+func ReadPGMInto(filename string, dst *PGM) error {
+	// ReadPGMInto: This function reads a PGM file into dst, reusing the pixel buffers of dst when the dimensions match. The content of dst is undefined if an error is returned.
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
 	defer file.Close()
 
 	reader := bufio.NewReader(file)
 
 	// Read magic number
-	magicNumber, err := reader.ReadString('\n')
+	magicNumber, err := readLine(reader)
 	if err != nil {
-		return nil, fmt.Errorf("error reading magic number: %v", err)
+		return fmt.Errorf("%w: error reading magic number: %v", ErrInvalidHeader, err)
 	}
 
 	magicNumber = strings.TrimSpace(magicNumber)
 	if magicNumber != "P2" && magicNumber != "P5" {
-		return nil, fmt.Errorf("invalid magic number: %s", magicNumber)
+		return fmt.Errorf("%w: invalid magic number: %s", ErrUnsupportedFormat, magicNumber)
 	}
 
 	// Read dimensions
-	dimensions, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, fmt.Errorf("error reading dimensions: %v", err)
-	}
-
-	var width, height int
-	_, err = fmt.Sscanf(strings.TrimSpace(dimensions), "%d %d", &width, &height)
+	width, height, err := readDimensions(reader)
 	if err != nil {
-		return nil, fmt.Errorf("invalid dimensions: %v", err)
+		return fmt.Errorf("%w: invalid dimensions: %v", ErrInvalidHeader, err)
 	}
 
 	if width <= 0 || height <= 0 {
-		return nil, fmt.Errorf("invalid dimensions: width and height must be positive")
+		return fmt.Errorf("%w: width and height must be positive", ErrInvalidHeader)
 	}
 
 	// Read max value
-	maxValue, err := reader.ReadString('\n')
+	// The max value is read as a token too, so trailing whitespace after the dimensions is allowed
+	maxValue, err := readToken(reader)
 	if err != nil {
-		return nil, fmt.Errorf("error reading max value: %v", err)
+		return fmt.Errorf("%w: error reading max value: %v", ErrInvalidHeader, err)
 	}
 
-	maxValue = strings.TrimSpace(maxValue)
 	var max uint8
 	_, err = fmt.Sscanf(maxValue, "%d", &max)
 	if err != nil {
-		return nil, fmt.Errorf("invalid max value: %v", err)
+		return fmt.Errorf("%w: invalid max value: %v", ErrInvalidHeader, err)
+	}
+
+	if max < 1 {
+		return fmt.Errorf("%w: max value must be at least 1", ErrInvalidHeader)
+	}
+
+	// Reuse the existing buffers when the dimensions match, otherwise reallocate
+	data := dst.data
+	reuse := len(data) == height
+	for y := 0; reuse && y < height; y++ {
+		reuse = len(data[y]) == width
+	}
+
+	if !reuse {
+		data = make([][]uint8, height)
+		for y := range data {
+			data[y] = make([]uint8, width)
+		}
+
 	}
 
 	// Read image data
-	data := make([][]uint8, height)
 	expectedBytesPerPixel := 1
 
 	if magicNumber == "P2" {
 		// Read P2 format (ASCII)
+		// Samples may be laid out with any whitespace, so read them one token at a time
 		for y := 0; y < height; y++ {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				return nil, fmt.Errorf("error reading data at row %d: %v", y, err)
-			}
+			for x := 0; x < width; x++ {
+				field, err := readToken(reader)
+				if err != nil {
+					if err == io.EOF {
+						return fmt.Errorf("%w at row %d", ErrTruncated, y)
+					}
 
-			fields := strings.Fields(line)
-			rowData := make([]uint8, width)
-			for x, field := range fields {
-				if x >= width {
-					return nil, fmt.Errorf("index out of range at row %d", y)
+					return fmt.Errorf("error reading data at row %d: %v", y, err)
 				}
 
 				var pixelValue uint8
-				_, err := fmt.Sscanf(field, "%d", &pixelValue)
+				_, err = fmt.Sscanf(field, "%d", &pixelValue)
 				if err != nil {
-					return nil, fmt.Errorf("error parsing pixel value at row %d, column %d: %v", y, x, err)
+					return fmt.Errorf("error parsing pixel value at row %d, column %d: %v", y, x, err)
 				}
 
-				rowData[x] = pixelValue
+				data[y][x] = pixelValue
 			}
 
-			data[y] = rowData
 		}
 
 	} else if magicNumber == "P5" {
 		// Read P5 format (binary)
+		row := make([]byte, width*expectedBytesPerPixel)
 		for y := 0; y < height; y++ {
-			row := make([]byte, width*expectedBytesPerPixel)
-			n, err := reader.Read(row)
+			n, err := io.ReadFull(reader, row)
 			if err != nil {
-				if err == io.EOF {
-					return nil, fmt.Errorf("unexpected end of file at row %d", y)
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					return fmt.Errorf("%w at row %d, expected %d bytes, got %d", ErrTruncated, y, width*expectedBytesPerPixel, n)
 				}
 
-				return nil, fmt.Errorf("error reading pixel data at row %d: %v", y, err)
+				return fmt.Errorf("error reading pixel data at row %d: %v", y, err)
 			}
 
-			if n < width*expectedBytesPerPixel {
-				return nil, fmt.Errorf("unexpected end of file at row %d, expected %d bytes, got %d", y, width*expectedBytesPerPixel, n)
-			}
-
-			rowData := make([]uint8, width)
+			rowData := data[y]
 			for x := 0; x < width; x++ {
 				pixelValue := uint8(row[x*expectedBytesPerPixel])
 				rowData[x] = pixelValue
 			}
 
-			data[y] = rowData
 		}
 
 	}
 
-	// Return the PGM struct
-	return &PGM{data, width, height, magicNumber, uint(max)}, nil
+	// Fill the PGM struct
+	*dst = PGM{data, width, height, magicNumber, uint(max)}
+	return nil
 }
 
 func (pgm *PGM) Size() (int, int) {
@@ -154,8 +178,37 @@ func (pgm *PGM) Set(x, y int, value uint8) {
 
 }
 
+// Validate checks that the pixel data matches the width and height of the image and that no sample exceeds the max value.
+func (pgm *PGM) Validate() error {
+	if len(pgm.data) != pgm.height {
+		return fmt.Errorf("invalid data: expected %d rows, got %d", pgm.height, len(pgm.data))
+	}
+
+	for y, row := range pgm.data {
+		if len(row) != pgm.width {
+			return fmt.Errorf("invalid data: expected %d pixels at row %d, got %d", pgm.width, y, len(row))
+		}
+
+		for x, value := range row {
+			if uint(value) > pgm.max {
+				return fmt.Errorf("invalid pixel value %d at row %d, column %d: exceeds max value %d", value, y, x, pgm.max)
+			}
+
+		}
+
+	}
+
+	return nil
+}
+
 // Save saves the PGM image to a file in the opposite format (P2 or P5) and returns an error if there was a problem.
+// P2 lines are wrapped at 70 characters.
 func (pgm *PGM) Save(filename string) error {
+	return pgm.SaveWithOptions(filename, ASCIIOptions{Layout: LayoutRow, MaxLineWidth: maxLineWidth})
+}
+
+// SaveWithOptions saves the PGM image to a file, laying out P2 samples as described by opts.
+func (pgm *PGM) SaveWithOptions(filename string, opts ASCIIOptions) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -190,7 +243,7 @@ func (pgm *PGM) Save(filename string) error {
 
 	// Write image data
 	if pgm.magicNumber == "P2" {
-		err = saveP2PGM(writer, pgm)
+		err = saveP2PGM(writer, pgm, opts)
 		if err != nil {
 			return err
 		}
@@ -207,32 +260,24 @@ func (pgm *PGM) Save(filename string) error {
 }
 
 // saveP2PGM saves the PGM image in P2 format (ASCII).
-func saveP2PGM(file *bufio.Writer, pgm *PGM) error {
+func saveP2PGM(file *bufio.Writer, pgm *PGM, opts ASCIIOptions) error {
+	aw := &asciiWriter{w: file, maxWidth: opts.MaxLineWidth}
 	for y := 0; y < pgm.height; y++ {
 		for x := 0; x < pgm.width; x++ {
 			// Write the pixel value
-			_, err := fmt.Fprint(file, pgm.data[y][x])
-			if err != nil {
-				return fmt.Errorf("error writing pixel data at row %d, column %d: %v", y, x, err)
-			}
-
-			// Add a space after each pixel, except the last one in a row
-			if x < pgm.width-1 {
-				_, err = fmt.Fprint(file, " ")
-				if err != nil {
-					return fmt.Errorf("error writing space after pixel at row %d, column %d: %v", y, x, err)
-				}
-
+			aw.sample(uint(pgm.data[y][x]))
+			if opts.Layout != LayoutRow {
+				aw.newline()
 			}
 
 		}
 
 		// Add a newline after each row
-		_, err := fmt.Fprintln(file)
-		if err != nil {
-			return fmt.Errorf("error writing newline after row %d: %v", y, err)
-		}
+		aw.newline()
+	}
 
+	if aw.err != nil {
+		return fmt.Errorf("error writing pixel data: %v", aw.err)
 	}
 
 	return nil
@@ -344,3 +389,692 @@ func (pgm *PGM) ToPBM() *PBM {
 
 	return pbm
 }
+
+// ToPPM converts the PGM image to PPM, keeping the max value of the source image.
+func (pgm *PGM) ToPPM() *PPM {
+	ppm := &PPM{
+		data:        make([][]Pixel, pgm.height),
+		width:       pgm.width,
+		height:      pgm.height,
+		magicNumber: "P3",
+		max:         pgm.max,
+	}
+
+	for y := 0; y < pgm.height; y++ {
+		ppm.data[y] = make([]Pixel, pgm.width)
+		for x := 0; x < pgm.width; x++ {
+			value := pgm.data[y][x]
+			ppm.data[y][x] = Pixel{value, value, value}
+		}
+
+	}
+
+	return ppm
+}
+
+// plane returns the samples of the PGM image as floating point values.
+func (pgm *PGM) plane() [][]float64 {
+	plane := make([][]float64, pgm.height)
+	for y := 0; y < pgm.height; y++ {
+		plane[y] = make([]float64, pgm.width)
+		for x := 0; x < pgm.width; x++ {
+			plane[y][x] = float64(pgm.data[y][x])
+		}
+
+	}
+
+	return plane
+}
+
+// setPlane replaces the samples of the PGM image, rounding and clamping them to [0, max].
+func (pgm *PGM) setPlane(plane [][]float64) {
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			pgm.data[y][x] = clampSample(plane[y][x], pgm.max)
+		}
+
+	}
+
+}
+
+// GaussianBlur blurs the PGM image with a Gaussian of the given standard deviation.
+func (pgm *PGM) GaussianBlur(sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	kernel := gaussianKernel(sigma)
+	pgm.setPlane(convolvePlane(pgm.plane(), kernel, kernel))
+}
+
+// ConvolveSeparable convolves the PGM image with the kernel formed by the outer product of vert and horiz,
+// as a horizontal then a vertical 1D pass, and divides the result by divisor (treated as 1 when zero).
+// Samples outside the image are clamped to the nearest edge.
+func (pgm *PGM) ConvolveSeparable(horiz, vert []float64, divisor float64) {
+	if len(horiz) == 0 || len(vert) == 0 {
+		return
+	}
+
+	pgm.setPlane(convolvePlane(pgm.plane(), horiz, scaledKernel(vert, divisor)))
+}
+
+// GaussianBlurParallel is like GaussianBlur but splits the rows of each pass between GOMAXPROCS goroutines.
+// The result is identical to GaussianBlur.
+func (pgm *PGM) GaussianBlurParallel(sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	kernel := gaussianKernel(sigma)
+	pgm.setPlane(convolvePlaneParallel(pgm.plane(), kernel, kernel, runtime.GOMAXPROCS(0)))
+}
+
+// UnsharpMask sharpens the PGM image by adding amount times the difference between the image and its Gaussian blur.
+func (pgm *PGM) UnsharpMask(sigma float64, amount float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	kernel := gaussianKernel(sigma)
+	original := pgm.plane()
+	pgm.setPlane(unsharpPlane(original, convolvePlane(original, kernel, kernel), amount))
+}
+
+// Laplacian returns a new PGM image with the response of the 3×3 Laplacian kernel, normalized to [0, max].
+// With absolute set, the magnitude of the response is used and flat regions map to 0, otherwise flat regions map to max/2.
+func (pgm *PGM) Laplacian(absolute bool) *PGM {
+	response := laplacianPlane(pgm.plane())
+
+	peak := 0.0
+	for y := range response {
+		for x := range response[y] {
+			peak = math.Max(peak, math.Abs(response[y][x]))
+		}
+
+	}
+
+	result := &PGM{
+		data:        make([][]uint8, pgm.height),
+		width:       pgm.width,
+		height:      pgm.height,
+		magicNumber: pgm.magicNumber,
+		max:         pgm.max,
+	}
+
+	maxValue := float64(pgm.max)
+	for y := 0; y < pgm.height; y++ {
+		result.data[y] = make([]uint8, pgm.width)
+		for x := 0; x < pgm.width; x++ {
+			var value float64
+			if absolute {
+				if peak > 0 {
+					value = math.Abs(response[y][x]) / peak * maxValue
+				}
+
+			} else {
+				value = maxValue / 2
+				if peak > 0 {
+					value += response[y][x] / peak * maxValue / 2
+				}
+
+			}
+
+			result.data[y][x] = clampSample(value, pgm.max)
+		}
+
+	}
+
+	return result
+}
+
+// IntegralTable is a summed-area table built by IntegralImage. It has height+1 rows and width+1 columns:
+// entry [y][x] is the sum of all samples above and to the left of (x, y).
+type IntegralTable [][]uint64
+
+// IntegralImage returns the summed-area table of the PGM image.
+func (pgm *PGM) IntegralImage() IntegralTable {
+	table := make(IntegralTable, pgm.height+1)
+	table[0] = make([]uint64, pgm.width+1)
+	for y := 0; y < pgm.height; y++ {
+		table[y+1] = make([]uint64, pgm.width+1)
+		var rowSum uint64
+		for x := 0; x < pgm.width; x++ {
+			rowSum += uint64(pgm.data[y][x])
+			table[y+1][x+1] = table[y][x+1] + rowSum
+		}
+
+	}
+
+	return table
+}
+
+// BoxSum returns the sum of the samples inside r in constant time. The rectangle is clipped to the image.
+func (table IntegralTable) BoxSum(r Rect) uint64 {
+	if len(table) == 0 {
+		return 0
+	}
+
+	x0 := min(max(r.X, 0), len(table[0])-1)
+	y0 := min(max(r.Y, 0), len(table)-1)
+	x1 := min(max(r.X+r.Width, x0), len(table[0])-1)
+	y1 := min(max(r.Y+r.Height, y0), len(table)-1)
+	return table[y1][x1] - table[y0][x1] - table[y1][x0] + table[y0][x0]
+}
+
+// BoxBlur replaces each sample of the PGM image by the mean of its (2*radius+1)² neighborhood, in constant time per pixel.
+// Near the borders only the part of the neighborhood inside the image is averaged.
+func (pgm *PGM) BoxBlur(radius int) {
+	if radius <= 0 {
+		return
+	}
+
+	table := pgm.IntegralImage()
+	for y := 0; y < pgm.height; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, pgm.height)
+		for x := 0; x < pgm.width; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, pgm.width)
+			n := uint64((x1 - x0) * (y1 - y0))
+			sum := table.BoxSum(Rect{x0, y0, x1 - x0, y1 - y0})
+			pgm.data[y][x] = uint8((sum + n/2) / n)
+		}
+
+	}
+
+}
+
+// CannyEdges returns a PBM edge map of the PGM image using the Canny algorithm: Gaussian smoothing, Sobel gradients,
+// non-maximum suppression and hysteresis. Gradients above high start an edge, which is extended through gradients above low.
+func (pgm *PGM) CannyEdges(low, high float64) *PBM {
+	kernel := gaussianKernel(1)
+	gx, gy := sobelPlane(convolvePlane(pgm.plane(), kernel, kernel))
+
+	magnitude := make([][]float64, pgm.height)
+	for y := 0; y < pgm.height; y++ {
+		magnitude[y] = make([]float64, pgm.width)
+		for x := 0; x < pgm.width; x++ {
+			magnitude[y][x] = math.Hypot(gx[y][x], gy[y][x])
+		}
+
+	}
+
+	// Keep only the local maxima along the gradient direction
+	at := func(x, y int) float64 {
+		if x < 0 || x >= pgm.width || y < 0 || y >= pgm.height {
+			return 0
+		}
+
+		return magnitude[y][x]
+	}
+
+	thin := make([][]float64, pgm.height)
+	for y := 0; y < pgm.height; y++ {
+		thin[y] = make([]float64, pgm.width)
+		for x := 0; x < pgm.width; x++ {
+			angle := math.Mod(math.Atan2(gy[y][x], gx[y][x])*180/math.Pi+180, 180)
+			var dx, dy int
+			switch {
+			case angle < 22.5 || angle >= 157.5:
+				dx, dy = 1, 0
+			case angle < 67.5:
+				dx, dy = 1, 1
+			case angle < 112.5:
+				dx, dy = 0, 1
+			default:
+				dx, dy = -1, 1
+			}
+
+			m := magnitude[y][x]
+			if m >= at(x+dx, y+dy) && m > at(x-dx, y-dy) {
+				thin[y][x] = m
+			}
+
+		}
+
+	}
+
+	// Hysteresis: grow edges from strong pixels through weak ones
+	pbm := &PBM{
+		data:        make([][]bool, pgm.height),
+		width:       pgm.width,
+		height:      pgm.height,
+		magicNumber: "P1",
+	}
+
+	for y := range pbm.data {
+		pbm.data[y] = make([]bool, pgm.width)
+	}
+
+	var stack []Point
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			if thin[y][x] >= high && !pbm.data[y][x] {
+				pbm.data[y][x] = true
+				stack = append(stack, Point{x, y})
+			}
+
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				for ny := max(p.Y-1, 0); ny <= min(p.Y+1, pgm.height-1); ny++ {
+					for nx := max(p.X-1, 0); nx <= min(p.X+1, pgm.width-1); nx++ {
+						if !pbm.data[ny][nx] && thin[ny][nx] >= low {
+							pbm.data[ny][nx] = true
+							stack = append(stack, Point{nx, ny})
+						}
+
+					}
+
+				}
+
+			}
+
+		}
+
+	}
+
+	return pbm
+}
+
+// CountIf returns the number of pixels of the PGM image that satisfy pred.
+func (pgm *PGM) CountIf(pred func(uint8) bool) int {
+	count := 0
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			if pred(pgm.data[y][x]) {
+				count++
+			}
+
+		}
+
+	}
+
+	return count
+}
+
+// Stats returns the minimum, maximum, mean and standard deviation of the samples of the PGM image, computed in a single pass.
+func (pgm *PGM) Stats() (min, max uint8, mean, stddev float64) {
+	if pgm.width <= 0 || pgm.height <= 0 {
+		return 0, 0, 0, 0
+	}
+
+	min = 255
+	var sum, sumSquares float64
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			value := pgm.data[y][x]
+			if value < min {
+				min = value
+			}
+
+			if value > max {
+				max = value
+			}
+
+			sum += float64(value)
+			sumSquares += float64(value) * float64(value)
+		}
+
+	}
+
+	n := float64(pgm.width * pgm.height)
+	mean = sum / n
+	stddev = math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+	return min, max, mean, stddev
+}
+
+// IsUniform reports whether all pixels of the PGM image have the same value and, if so, returns that value.
+func (pgm *PGM) IsUniform() (bool, uint8) {
+	if pgm.width <= 0 || pgm.height <= 0 {
+		return false, 0
+	}
+
+	first := pgm.data[0][0]
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			if pgm.data[y][x] != first {
+				return false, 0
+			}
+
+		}
+
+	}
+
+	return true, first
+}
+
+// ToImage converts the PGM image to the Go image.Image interface as 8-bit grayscale.
+func (pgm *PGM) ToImage() image.Image {
+	bounds := image.Rect(0, 0, pgm.width, pgm.height)
+	img := image.NewGray(bounds)
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			img.SetGray(x, y, color.Gray{pgm.data[y][x]})
+		}
+
+	}
+
+	return img
+}
+
+// SavePNG saves the PGM image as a grayscale PNG file, using 16 bits per sample when the max value is above 255.
+func (pgm *PGM) SavePNG(filename string) error {
+	img := pgm.ToImage()
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return png.Encode(file, img)
+}
+
+// MotionMask returns a PBM image marking the pixels whose absolute difference between prev and curr exceeds threshold.
+func MotionMask(prev, curr *PGM, threshold uint8) (*PBM, error) {
+	if prev == nil || curr == nil {
+		return nil, fmt.Errorf("cannot compare a nil PGM")
+	}
+
+	if prev.width != curr.width || prev.height != curr.height {
+		return nil, fmt.Errorf("size mismatch: %dx%d and %dx%d", prev.width, prev.height, curr.width, curr.height)
+	}
+
+	mask := newPBM(curr.width, curr.height, "P1")
+	for y := 0; y < curr.height; y++ {
+		for x := 0; x < curr.width; x++ {
+			a, b := prev.data[y][x], curr.data[y][x]
+			diff := a - b
+			if b > a {
+				diff = b - a
+			}
+
+			mask.data[y][x] = diff > threshold
+		}
+
+	}
+
+	return mask, nil
+}
+
+// Bytes returns the pixels of the PGM image as one byte per pixel in row-major order: pixel (x, y) is at index y*width+x.
+func (pgm *PGM) Bytes() []byte {
+	data := make([]byte, 0, pgm.width*pgm.height)
+	for y := 0; y < pgm.height; y++ {
+		data = append(data, pgm.data[y][:pgm.width]...)
+	}
+
+	return data
+}
+
+// Row returns a copy of row y of the PGM image, or nil if y is out of range.
+func (pgm *PGM) Row(y int) []uint8 {
+	if y < 0 || y >= pgm.height {
+		return nil
+	}
+
+	row := make([]uint8, pgm.width)
+	copy(row, pgm.data[y])
+	return row
+}
+
+// Column returns a copy of column x of the PGM image, or nil if x is out of range.
+func (pgm *PGM) Column(x int) []uint8 {
+	if x < 0 || x >= pgm.width {
+		return nil
+	}
+
+	column := make([]uint8, pgm.height)
+	for y := range column {
+		column[y] = pgm.data[y][x]
+	}
+
+	return column
+}
+
+// BlockDCT returns the 8×8 block DCT of the PGM image. The image is split into 8×8 blocks, padding the last blocks by
+// repeating the edge samples, and the coefficient (u, v) of the block at (bx, by) is stored at [by*8+v][bx*8+u].
+func (pgm *PGM) BlockDCT() [][]float64 {
+	height := (pgm.height + dctBlockSize - 1) / dctBlockSize * dctBlockSize
+	width := (pgm.width + dctBlockSize - 1) / dctBlockSize * dctBlockSize
+	basis := dctBasis()
+
+	coefficients := make([][]float64, height)
+	for y := range coefficients {
+		coefficients[y] = make([]float64, width)
+	}
+
+	var block [dctBlockSize][dctBlockSize]float64
+	for by := 0; by < height; by += dctBlockSize {
+		for bx := 0; bx < width; bx += dctBlockSize {
+			for y := 0; y < dctBlockSize; y++ {
+				for x := 0; x < dctBlockSize; x++ {
+					block[y][x] = float64(pgm.data[min(by+y, pgm.height-1)][min(bx+x, pgm.width-1)])
+				}
+
+			}
+
+			for v := 0; v < dctBlockSize; v++ {
+				for u := 0; u < dctBlockSize; u++ {
+					sum := 0.0
+					for y := 0; y < dctBlockSize; y++ {
+						for x := 0; x < dctBlockSize; x++ {
+							sum += basis[u][x] * basis[v][y] * block[y][x]
+						}
+
+					}
+
+					coefficients[by+v][bx+u] = sum
+				}
+
+			}
+
+		}
+
+	}
+
+	return coefficients
+}
+
+// FromBlockDCT replaces the samples of the PGM image with the inverse of coefficients laid out as returned by BlockDCT.
+// Results are rounded and clamped to [0, max].
+func (pgm *PGM) FromBlockDCT(coefficients [][]float64) error {
+	height := (pgm.height + dctBlockSize - 1) / dctBlockSize * dctBlockSize
+	width := (pgm.width + dctBlockSize - 1) / dctBlockSize * dctBlockSize
+	if len(coefficients) != height {
+		return fmt.Errorf("invalid coefficients: expected %d rows, got %d", height, len(coefficients))
+	}
+
+	for y, row := range coefficients {
+		if len(row) != width {
+			return fmt.Errorf("invalid coefficients: expected %d columns at row %d, got %d", width, y, len(row))
+		}
+
+	}
+
+	basis := dctBasis()
+	for by := 0; by < height; by += dctBlockSize {
+		for bx := 0; bx < width; bx += dctBlockSize {
+			for y := 0; y < dctBlockSize && by+y < pgm.height; y++ {
+				for x := 0; x < dctBlockSize && bx+x < pgm.width; x++ {
+					sum := 0.0
+					for v := 0; v < dctBlockSize; v++ {
+						for u := 0; u < dctBlockSize; u++ {
+							sum += basis[u][x] * basis[v][y] * coefficients[by+v][bx+u]
+						}
+
+					}
+
+					pgm.data[by+y][bx+x] = clampSample(sum, pgm.max)
+				}
+
+			}
+
+		}
+
+	}
+
+	return nil
+}
+
+// Contours returns a PBM image marking the 1px outline of the regions brighter than threshold:
+// a pixel is set when it is above threshold and at least one of its 4 neighbors is not, or lies outside the image.
+func (pgm *PGM) Contours(threshold uint8) *PBM {
+	inside := func(x, y int) bool {
+		return x >= 0 && x < pgm.width && y >= 0 && y < pgm.height && pgm.data[y][x] > threshold
+	}
+
+	pbm := newPBM(pgm.width, pgm.height, "P1")
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			if inside(x, y) {
+				pbm.data[y][x] = !inside(x-1, y) || !inside(x+1, y) || !inside(x, y-1) || !inside(x, y+1)
+			}
+
+		}
+
+	}
+
+	return pbm
+}
+
+// Reset sets every pixel of the PGM image to 0, keeping its dimensions.
+func (pgm *PGM) Reset() {
+	for y := range pgm.data {
+		clear(pgm.data[y])
+	}
+
+}
+
+// SaveAtomic saves the PGM image like Save, but writes to a temporary file first and renames it over filename,
+// so an error while writing never leaves a partially written file behind.
+func (pgm *PGM) SaveAtomic(filename string) error {
+	return saveAtomic(filename, pgm.Save)
+}
+
+// Coverage returns the mean intensity of the PGM image as a fraction of its max value, in [0, 1].
+func (pgm *PGM) Coverage() float64 {
+	if pgm.max == 0 {
+		return 0
+	}
+
+	_, _, mean, _ := pgm.Stats()
+	return mean / float64(pgm.max)
+}
+
+// Checksum returns a CRC32 of the dimensions and pixels of the PGM image, in the layout returned by Bytes.
+func (pgm *PGM) Checksum() uint32 {
+	return checksum(pgm.width, pgm.height, pgm.Bytes())
+}
+
+// ApplyLUT remaps every sample of the PGM image through the 256-entry lookup table.
+func (pgm *PGM) ApplyLUT(lut [256]uint8) {
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			pgm.data[y][x] = lut[pgm.data[y][x]]
+		}
+
+	}
+
+}
+
+// Sharpness returns the variance of the Laplacian response of the PGM image. Sharper images score higher.
+func (pgm *PGM) Sharpness() float64 {
+	if pgm.width <= 0 || pgm.height <= 0 {
+		return 0
+	}
+
+	response := laplacianPlane(pgm.plane())
+	var sum, sumSquares float64
+	for y := range response {
+		for _, v := range response[y] {
+			sum += v
+			sumSquares += v * v
+		}
+
+	}
+
+	n := float64(pgm.width * pgm.height)
+	mean := sum / n
+	return math.Max(sumSquares/n-mean*mean, 0)
+}
+
+// RotateCrop rotates the PGM image clockwise by angle degrees around its center, keeping its dimensions.
+// Corners rotated out of the canvas are cropped and uncovered pixels are set to background.
+func (pgm *PGM) RotateCrop(angle float64, background uint8) {
+	pgm.RotateCropWith(angle, background, Nearest)
+}
+
+// RotateCropWith is like RotateCrop but samples the source image with the given interpolation.
+func (pgm *PGM) RotateCropWith(angle float64, background uint8, interp Interpolation) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	cx, cy := float64(pgm.width-1)/2, float64(pgm.height-1)/2
+
+	newData := make([][]uint8, pgm.height)
+	for y := 0; y < pgm.height; y++ {
+		newData[y] = make([]uint8, pgm.width)
+		for x := 0; x < pgm.width; x++ {
+			// Map the destination pixel back to the source image
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := cx + dx*cos + dy*sin
+			sy := cy - dx*sin + dy*cos
+			if !insideRounded(sx, sy, pgm.width, pgm.height) {
+				newData[y][x] = background
+			} else if interp == Bilinear {
+				newData[y][x] = pgm.AtBilinear(sx, sy)
+			} else {
+				newData[y][x] = pgm.data[int(math.Round(sy))][int(math.Round(sx))]
+			}
+
+		}
+
+	}
+
+	pgm.data = newData
+}
+
+// AtBilinear returns the value at (x, y) interpolated between the four nearest pixels.
+// Coordinates outside the image are clamped to its edges.
+func (pgm *PGM) AtBilinear(x, y float64) uint8 {
+	x0, y0, x1, y1, fx, fy := bilinearWeights(x, y, pgm.width, pgm.height)
+	top := float64(pgm.data[y0][x0])*(1-fx) + float64(pgm.data[y0][x1])*fx
+	bottom := float64(pgm.data[y1][x0])*(1-fx) + float64(pgm.data[y1][x1])*fx
+	return uint8(math.Round(top*(1-fy) + bottom*fy))
+}
+
+// GrayErode replaces every pixel of the PGM image with the minimum of the (2*radius+1)×(2*radius+1) square around it.
+func (pgm *PGM) GrayErode(radius int) {
+	if radius <= 0 {
+		return
+	}
+
+	pgm.data = extremumFilter(pgm.data, radius, false)
+}
+
+// GrayDilate replaces every pixel of the PGM image with the maximum of the (2*radius+1)×(2*radius+1) square around it.
+func (pgm *PGM) GrayDilate(radius int) {
+	if radius <= 0 {
+		return
+	}
+
+	pgm.data = extremumFilter(pgm.data, radius, true)
+}
+
+// TopHat replaces the PGM image with its white top-hat: the original minus its morphological opening
+// (GrayErode then GrayDilate) with the given radius. Bright features smaller than the square window are kept
+// while the slowly varying background is removed.
+func (pgm *PGM) TopHat(radius int) {
+	if radius <= 0 {
+		return
+	}
+
+	opening := extremumFilter(extremumFilter(pgm.data, radius, false), radius, true)
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			// The opening never exceeds the original, so the difference cannot underflow
+			pgm.data[y][x] -= opening[y][x]
+		}
+
+	}
+
+}
@@ -0,0 +1,723 @@
+package Netpbm
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestReadPGMCRLFHeader(t *testing.T) {
+	pgm, err := ReadPGM("testdata/crlf.pgm")
+	if err != nil {
+		t.Fatalf("ReadPGM: %v", err)
+	}
+
+	want := [][]uint8{{0x10, 0x20}, {0x30, 0x40}}
+	if !reflect.DeepEqual(pgm.data, want) {
+		t.Errorf("data = %v, want %v", pgm.data, want)
+	}
+}
+
+func TestPGMValidateJagged(t *testing.T) {
+	pgm := &PGM{data: [][]uint8{{1, 2}, {3, 4, 5}}, width: 2, height: 2, magicNumber: "P2", max: 255}
+	err := pgm.Validate()
+	if err == nil {
+		t.Fatal("Validate succeeded on a jagged grid")
+	}
+	if want := "invalid data: expected 2 pixels at row 1, got 3"; err.Error() != want {
+		t.Errorf("Validate() = %q, want %q", err, want)
+	}
+
+	pgm.data[1] = pgm.data[1][:2]
+	pgm.max = 3
+	if err := pgm.Validate(); err == nil || err.Error() != "invalid pixel value 4 at row 1, column 1: exceeds max value 3" {
+		t.Errorf("Validate() with a sample above max = %v", err)
+	}
+}
+
+// writeP5 writes a width×height P5 file with a simple gradient and returns its name.
+func writeP5(tb testing.TB, width, height int) string {
+	tb.Helper()
+	data := []byte(fmt.Sprintf("P5\n%d %d\n255\n", width, height))
+	for i := 0; i < width*height; i++ {
+		data = append(data, uint8(i))
+	}
+	filename := filepath.Join(tb.TempDir(), "gradient.pgm")
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return filename
+}
+
+func TestReadPGMIntoReusesBuffers(t *testing.T) {
+	filename := writeP5(t, 5, 3)
+	var dst PGM
+	if err := ReadPGMInto(filename, &dst); err != nil {
+		t.Fatalf("ReadPGMInto: %v", err)
+	}
+	rows := append([][]uint8(nil), dst.data...)
+
+	dst.data[1][2] = 0xff
+	if err := ReadPGMInto(filename, &dst); err != nil {
+		t.Fatalf("ReadPGMInto: %v", err)
+	}
+	for y := range rows {
+		if &rows[y][0] != &dst.data[y][0] {
+			t.Errorf("row %d was reallocated", y)
+		}
+	}
+
+	want, err := ReadPGM(filename)
+	if err != nil {
+		t.Fatalf("ReadPGM: %v", err)
+	}
+	if !reflect.DeepEqual(dst, *want) {
+		t.Errorf("ReadPGMInto = %v, want %v", dst, *want)
+	}
+
+	// Different dimensions must reallocate
+	if err := ReadPGMInto(writeP5(t, 2, 4), &dst); err != nil {
+		t.Fatalf("ReadPGMInto: %v", err)
+	}
+	if w, h := dst.Size(); w != 2 || h != 4 || len(dst.data) != 4 || len(dst.data[0]) != 2 {
+		t.Errorf("Size() = %d, %d with %d rows, want 2, 4", w, h, len(dst.data))
+	}
+}
+
+func BenchmarkReadPGM(b *testing.B) {
+	filename := writeP5(b, 512, 512)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadPGM(filename); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadPGMInto(b *testing.B) {
+	filename := writeP5(b, 512, 512)
+	var dst PGM
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ReadPGMInto(filename, &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// noisePGM returns a width×height PGM filled with a deterministic pseudo-random pattern.
+func noisePGM(width, height int) *PGM {
+	pgm := &PGM{data: make([][]uint8, height), width: width, height: height, magicNumber: "P2", max: 255}
+	seed := uint32(1)
+	for y := range pgm.data {
+		pgm.data[y] = make([]uint8, width)
+		for x := range pgm.data[y] {
+			seed = seed*1664525 + 1013904223
+			pgm.data[y][x] = uint8(seed >> 24)
+		}
+	}
+	return pgm
+}
+
+// naiveBoxBlur averages the (2*radius+1)² window around every pixel, cropped to the image, by summing it directly.
+func naiveBoxBlur(pgm *PGM, radius int) [][]uint8 {
+	out := make([][]uint8, pgm.height)
+	for y := range out {
+		out[y] = make([]uint8, pgm.width)
+		for x := range out[y] {
+			var sum, n uint64
+			for sy := max(y-radius, 0); sy <= min(y+radius, pgm.height-1); sy++ {
+				for sx := max(x-radius, 0); sx <= min(x+radius, pgm.width-1); sx++ {
+					sum += uint64(pgm.data[sy][sx])
+					n++
+				}
+			}
+			out[y][x] = uint8((sum + n/2) / n)
+		}
+	}
+	return out
+}
+
+func TestBoxBlurMatchesNaive(t *testing.T) {
+	for _, radius := range []int{1, 2, 5} {
+		pgm := noisePGM(23, 17)
+		want := naiveBoxBlur(pgm, radius)
+		pgm.BoxBlur(radius)
+		if !reflect.DeepEqual(pgm.data, want) {
+			t.Errorf("BoxBlur(%d) differs from the naive box blur", radius)
+		}
+	}
+}
+
+func BenchmarkBoxBlur(b *testing.B) {
+	pgm := noisePGM(256, 256)
+	for i := 0; i < b.N; i++ {
+		pgm.BoxBlur(7)
+	}
+}
+
+func BenchmarkNaiveBoxBlur(b *testing.B) {
+	pgm := noisePGM(256, 256)
+	for i := 0; i < b.N; i++ {
+		pgm.data = naiveBoxBlur(pgm, 7)
+	}
+}
+
+// convolve2D convolves the PGM image with the full outer product of vert and horiz, divided by divisor,
+// clamping samples outside the image to the nearest edge.
+func convolve2D(pgm *PGM, horiz, vert []float64, divisor float64) [][]uint8 {
+	hr, vr := len(horiz)/2, len(vert)/2
+	out := make([][]uint8, pgm.height)
+	for y := range out {
+		out[y] = make([]uint8, pgm.width)
+		for x := range out[y] {
+			sum := 0.0
+			for ky, vw := range vert {
+				sy := min(max(y+ky-vr, 0), pgm.height-1)
+				for kx, hw := range horiz {
+					sx := min(max(x+kx-hr, 0), pgm.width-1)
+					sum += vw * hw * float64(pgm.data[sy][sx])
+				}
+			}
+			out[y][x] = clampSample(sum/divisor, pgm.max)
+		}
+	}
+	return out
+}
+
+func TestConvolveSeparableMatches2D(t *testing.T) {
+	kernel := []float64{1, 4, 6, 4, 1}
+	pgm := noisePGM(31, 19)
+	want := convolve2D(pgm, kernel, kernel, 256)
+	pgm.ConvolveSeparable(kernel, kernel, 256)
+
+	for y := range want {
+		for x := range want[y] {
+			if d := int(pgm.data[y][x]) - int(want[y][x]); d < -1 || d > 1 {
+				t.Fatalf("pixel (%d, %d) = %d, want %d within rounding", x, y, pgm.data[y][x], want[y][x])
+			}
+		}
+	}
+}
+
+func BenchmarkConvolveSeparable(b *testing.B) {
+	kernel := gaussianKernel(3)
+	pgm := noisePGM(256, 256)
+	for i := 0; i < b.N; i++ {
+		pgm.ConvolveSeparable(kernel, kernel, 1)
+	}
+}
+
+func BenchmarkConvolve2D(b *testing.B) {
+	kernel := gaussianKernel(3)
+	pgm := noisePGM(256, 256)
+	for i := 0; i < b.N; i++ {
+		pgm.data = convolve2D(pgm, kernel, kernel, 1)
+	}
+}
+
+func TestGaussianBlurParallelMatchesGaussianBlur(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	want := noisePGM(67, 41)
+	got := noisePGM(67, 41)
+	want.GaussianBlur(2.5)
+	got.GaussianBlurParallel(2.5)
+	if !reflect.DeepEqual(got.data, want.data) {
+		t.Error("GaussianBlurParallel differs from GaussianBlur")
+	}
+}
+
+func BenchmarkGaussianBlur(b *testing.B) {
+	pgm := noisePGM(1024, 1024)
+	for i := 0; i < b.N; i++ {
+		pgm.GaussianBlur(2)
+	}
+}
+
+func BenchmarkGaussianBlurParallel(b *testing.B) {
+	pgm := noisePGM(1024, 1024)
+	for i := 0; i < b.N; i++ {
+		pgm.GaussianBlurParallel(2)
+	}
+}
+
+func TestReadPGMWhitespaceDimensions(t *testing.T) {
+	for _, header := range []string{"P2\n3\t2\n255\n", "P2\n3\n2\n255\n", "P2\n3 2 \n255\n"} {
+		filename := filepath.Join(t.TempDir(), "image.pgm")
+		if err := os.WriteFile(filename, []byte(header+"1 2 3\n4 5 6\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		pgm, err := ReadPGM(filename)
+		if err != nil {
+			t.Fatalf("ReadPGM(%q): %v", header, err)
+		}
+		if want := [][]uint8{{1, 2, 3}, {4, 5, 6}}; !reflect.DeepEqual(pgm.data, want) {
+			t.Errorf("ReadPGM(%q) data = %v, want %v", header, pgm.data, want)
+		}
+	}
+}
+
+func TestToPPMKeepsMaxValue(t *testing.T) {
+	pgm := &PGM{data: [][]uint8{{0, 50}, {99, 100}}, width: 2, height: 2, magicNumber: "P2", max: 100}
+	ppm := pgm.ToPPM()
+
+	if ppm.max != pgm.max {
+		t.Errorf("max = %d, want %d", ppm.max, pgm.max)
+	}
+	for y := 0; y < pgm.height; y++ {
+		for x := 0; x < pgm.width; x++ {
+			v := pgm.data[y][x]
+			if got := ppm.At(x, y); got != (Pixel{v, v, v}) {
+				t.Errorf("pixel (%d, %d) = %v, want {%d %d %d}", x, y, got, v, v, v)
+			}
+		}
+	}
+}
+
+// stepPGM returns a width×height PGM that is low on the left half and high on the right half.
+func stepPGM(width, height int, low, high uint8) *PGM {
+	pgm := &PGM{data: make([][]uint8, height), width: width, height: height, magicNumber: "P2", max: 255}
+	for y := range pgm.data {
+		pgm.data[y] = make([]uint8, width)
+		for x := range pgm.data[y] {
+			pgm.data[y][x] = low
+			if x >= width/2 {
+				pgm.data[y][x] = high
+			}
+		}
+	}
+	return pgm
+}
+
+func TestUnsharpMaskStepEdge(t *testing.T) {
+	pgm := stepPGM(20, 5, 50, 200)
+	pgm.UnsharpMask(1, 1)
+
+	row := pgm.data[2]
+	if row[9] >= 50 {
+		t.Errorf("dark side of the edge = %d, want undershoot below 50", row[9])
+	}
+	if row[10] <= 200 {
+		t.Errorf("bright side of the edge = %d, want overshoot above 200", row[10])
+	}
+	if row[0] != 50 || row[19] != 200 {
+		t.Errorf("flat regions changed: %d and %d, want 50 and 200", row[0], row[19])
+	}
+}
+
+func TestLaplacianFlatAndEdge(t *testing.T) {
+	pgm := stepPGM(20, 5, 50, 200)
+
+	absolute := pgm.Laplacian(true)
+	if v := absolute.data[2][2]; v != 0 {
+		t.Errorf("absolute response in a flat region = %d, want 0", v)
+	}
+	if v := max(absolute.data[2][9], absolute.data[2][10]); v != 255 {
+		t.Errorf("absolute response at the edge = %d, want 255", v)
+	}
+
+	signed := pgm.Laplacian(false)
+	if v := signed.data[2][2]; v != 128 {
+		t.Errorf("signed response in a flat region = %d, want 128", v)
+	}
+	if lo, hi := min(signed.data[2][9], signed.data[2][10]), max(signed.data[2][9], signed.data[2][10]); lo != 0 || hi != 255 {
+		t.Errorf("signed response at the edge = %d and %d, want 0 and 255", lo, hi)
+	}
+}
+
+func TestBoxSumMatchesBruteForce(t *testing.T) {
+	pgm := noisePGM(13, 9)
+	table := pgm.IntegralImage()
+	if len(table) != 10 || len(table[0]) != 14 {
+		t.Fatalf("table is %dx%d, want 14x10", len(table[0]), len(table))
+	}
+
+	for _, r := range []Rect{{0, 0, 13, 9}, {2, 3, 5, 4}, {12, 8, 1, 1}, {4, 4, 0, 3}, {-2, -1, 5, 4}, {10, 6, 10, 10}} {
+		var want uint64
+		for y := max(r.Y, 0); y < min(r.Y+r.Height, pgm.height); y++ {
+			for x := max(r.X, 0); x < min(r.X+r.Width, pgm.width); x++ {
+				want += uint64(pgm.data[y][x])
+			}
+		}
+		if got := table.BoxSum(r); got != want {
+			t.Errorf("BoxSum(%+v) = %d, want %d", r, got, want)
+		}
+	}
+}
+
+func TestCannyEdgesThinLine(t *testing.T) {
+	edges := stepPGM(20, 12, 40, 200).CannyEdges(20, 60)
+
+	column := -1
+	for y := 0; y < 12; y++ {
+		var xs []int
+		for x := 0; x < 20; x++ {
+			if edges.At(x, y) {
+				xs = append(xs, x)
+			}
+		}
+		// One pixel per row, all in the same column next to the step, makes a thin connected line
+		if len(xs) != 1 || xs[0] < 9 || xs[0] > 10 {
+			t.Fatalf("row %d has edge pixels at %v, want one pixel at x=9 or x=10", y, xs)
+		}
+		if column == -1 {
+			column = xs[0]
+		} else if xs[0] != column {
+			t.Errorf("row %d edge at x=%d, want x=%d like the first row", y, xs[0], column)
+		}
+	}
+
+	if n := stepPGM(20, 12, 100, 100).CannyEdges(20, 60).CountIf(func(v bool) bool { return v }); n != 0 {
+		t.Errorf("flat image has %d edge pixels, want 0", n)
+	}
+}
+
+func TestPGMCountIfBrighter(t *testing.T) {
+	pgm := noisePGM(17, 11)
+	want := 0
+	for _, row := range pgm.data {
+		for _, v := range row {
+			if v > 128 {
+				want++
+			}
+		}
+	}
+	if got := pgm.CountIf(func(v uint8) bool { return v > 128 }); got != want {
+		t.Errorf("CountIf(> 128) = %d, want %d", got, want)
+	}
+}
+
+func TestPGMStats(t *testing.T) {
+	uniform := stepPGM(4, 3, 77, 77)
+	if lo, hi, mean, stddev := uniform.Stats(); lo != 77 || hi != 77 || mean != 77 || stddev != 0 {
+		t.Errorf("uniform Stats() = %d, %d, %v, %v, want 77, 77, 77, 0", lo, hi, mean, stddev)
+	}
+
+	// Half the pixels are 10 and half are 30
+	twoValue := stepPGM(4, 3, 10, 30)
+	lo, hi, mean, stddev := twoValue.Stats()
+	if lo != 10 || hi != 30 || math.Abs(mean-20) > 1e-9 || math.Abs(stddev-10) > 1e-9 {
+		t.Errorf("two-value Stats() = %d, %d, %v, %v, want 10, 30, 20, 10", lo, hi, mean, stddev)
+	}
+}
+
+func TestPGMIsUniform(t *testing.T) {
+	if ok, value := stepPGM(4, 3, 9, 9).IsUniform(); !ok || value != 9 {
+		t.Errorf("IsUniform() of a solid image = %v, %d, want true, 9", ok, value)
+	}
+	if ok, _ := noisePGM(4, 3).IsUniform(); ok {
+		t.Error("IsUniform() of a noisy image = true")
+	}
+}
+
+func TestReadPGMWithoutFinalNewline(t *testing.T) {
+	pgm, err := ReadPGM("testdata/no_final_newline.pgm")
+	if err != nil {
+		t.Fatalf("ReadPGM: %v", err)
+	}
+	if want := [][]uint8{{1, 2, 3}, {4, 5, 6}}; !reflect.DeepEqual(pgm.data, want) {
+		t.Errorf("data = %v, want %v", pgm.data, want)
+	}
+}
+
+func TestMotionMaskMovingSquare(t *testing.T) {
+	square := func(x0 int) *PGM {
+		pgm := stepPGM(12, 8, 20, 20)
+		for y := 2; y < 5; y++ {
+			for x := x0; x < x0+3; x++ {
+				pgm.data[y][x] = 220
+			}
+		}
+		return pgm
+	}
+
+	// The square moves two pixels right: columns 2-3 are uncovered and 5-6 newly covered
+	mask, err := MotionMask(square(2), square(4), 50)
+	if err != nil {
+		t.Fatalf("MotionMask: %v", err)
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 12; x++ {
+			want := y >= 2 && y < 5 && (x == 2 || x == 3 || x == 5 || x == 6)
+			if mask.At(x, y) != want {
+				t.Errorf("mask (%d, %d) = %v, want %v", x, y, mask.At(x, y), want)
+			}
+		}
+	}
+
+	if _, err := MotionMask(square(2), stepPGM(3, 3, 0, 0), 50); err == nil {
+		t.Error("MotionMask of different sizes succeeded")
+	}
+}
+
+func TestPGMBytes(t *testing.T) {
+	pgm := noisePGM(5, 4)
+	data := pgm.Bytes()
+
+	if len(data) != 5*4 {
+		t.Fatalf("len(Bytes()) = %d, want %d", len(data), 5*4)
+	}
+	if data[0] != pgm.data[0][0] {
+		t.Errorf("first pixel byte = %d, want %d", data[0], pgm.data[0][0])
+	}
+	if data[2*5+3] != pgm.data[2][3] {
+		t.Errorf("pixel (3, 2) byte = %d, want %d", data[2*5+3], pgm.data[2][3])
+	}
+}
+
+func TestPGMRowColumn(t *testing.T) {
+	pgm := noisePGM(5, 3)
+
+	row := pgm.Row(2)
+	if !reflect.DeepEqual(row, pgm.data[2]) {
+		t.Errorf("Row(2) = %v, want %v", row, pgm.data[2])
+	}
+	column := pgm.Column(4)
+	if want := []uint8{pgm.data[0][4], pgm.data[1][4], pgm.data[2][4]}; !reflect.DeepEqual(column, want) {
+		t.Errorf("Column(4) = %v, want %v", column, want)
+	}
+
+	// The slices are copies
+	column[0]++
+	if pgm.data[0][4] == column[0] {
+		t.Error("modifying the column changed the image")
+	}
+
+	if pgm.Row(-1) != nil || pgm.Row(3) != nil || pgm.Column(-1) != nil || pgm.Column(5) != nil {
+		t.Error("out-of-range lines should return nil")
+	}
+}
+
+func TestBlockDCTRoundTrip(t *testing.T) {
+	pgm := noisePGM(13, 10)
+	want := make([][]uint8, pgm.height)
+	for y := range want {
+		want[y] = append([]uint8(nil), pgm.data[y]...)
+	}
+
+	coefficients := pgm.BlockDCT()
+	if len(coefficients) != 16 || len(coefficients[0]) != 16 {
+		t.Fatalf("coefficients are %dx%d, want 16x16", len(coefficients[0]), len(coefficients))
+	}
+	pgm.Reset()
+	if err := pgm.FromBlockDCT(coefficients); err != nil {
+		t.Fatalf("FromBlockDCT: %v", err)
+	}
+	for y := range want {
+		for x := range want[y] {
+			if d := int(pgm.data[y][x]) - int(want[y][x]); d < -1 || d > 1 {
+				t.Errorf("pixel (%d, %d) = %d, want %d", x, y, pgm.data[y][x], want[y][x])
+			}
+		}
+	}
+
+	if err := pgm.FromBlockDCT(coefficients[:8]); err == nil {
+		t.Error("expected an error for mismatched coefficients")
+	}
+}
+
+func TestContoursCircle(t *testing.T) {
+	const size, c, r = 21, 10, 7
+	pgm := &PGM{data: make([][]uint8, size), width: size, height: size, magicNumber: "P2", max: 255}
+	for y := range pgm.data {
+		pgm.data[y] = make([]uint8, size)
+		for x := range pgm.data[y] {
+			if (x-c)*(x-c)+(y-c)*(y-c) <= r*r {
+				pgm.data[y][x] = 200
+			}
+		}
+	}
+	outline := pgm.Contours(100)
+
+	set := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if !outline.data[y][x] {
+				continue
+			}
+			set++
+			d := math.Hypot(float64(x-c), float64(y-c))
+			if d > r || d < r-1.5 {
+				t.Errorf("pixel (%d, %d) at distance %.2f is not on the perimeter", x, y, d)
+			}
+		}
+	}
+	if set == 0 {
+		t.Fatal("no outline pixels")
+	}
+	for _, p := range []Point{{c, c - r}, {c, c + r}, {c - r, c}, {c + r, c}} {
+		if !outline.data[p.Y][p.X] {
+			t.Errorf("extreme point %v of the circle is not set", p)
+		}
+	}
+}
+
+func TestPGMReset(t *testing.T) {
+	pgm := noisePGM(5, 3)
+	pgm.Reset()
+
+	if pgm.width != 5 || pgm.height != 3 || len(pgm.data) != 3 || len(pgm.data[0]) != 5 {
+		t.Fatalf("size changed to %dx%d", pgm.width, pgm.height)
+	}
+	for y := range pgm.data {
+		for x, v := range pgm.data[y] {
+			if v != 0 {
+				t.Errorf("pixel (%d, %d) = %d, want 0", x, y, v)
+			}
+		}
+	}
+}
+
+func TestPGMCoverage(t *testing.T) {
+	pgm := stepPGM(6, 4, 0, 255)
+	if c := pgm.Coverage(); c != 0.5 {
+		t.Errorf("Coverage() = %v, want 0.5", c)
+	}
+	pgm = stepPGM(6, 4, 51, 51)
+	if c := pgm.Coverage(); math.Abs(c-0.2) > 1e-9 {
+		t.Errorf("Coverage() of a uniform image = %v, want 0.2", c)
+	}
+}
+
+func TestPGMApplyLUT(t *testing.T) {
+	pgm := noisePGM(8, 6)
+	want := make([][]uint8, pgm.height)
+	for y := range want {
+		want[y] = append([]uint8(nil), pgm.data[y]...)
+	}
+
+	var identity, threshold [256]uint8
+	for v := range identity {
+		identity[v] = uint8(v)
+		if v >= 128 {
+			threshold[v] = 255
+		}
+	}
+
+	pgm.ApplyLUT(identity)
+	if !reflect.DeepEqual(pgm.data, want) {
+		t.Errorf("identity LUT changed the image: got %v, want %v", pgm.data, want)
+	}
+
+	pgm.ApplyLUT(threshold)
+	for y := range pgm.data {
+		for x, v := range pgm.data[y] {
+			if expected := threshold[want[y][x]]; v != expected {
+				t.Errorf("pixel (%d, %d) = %d, want %d", x, y, v, expected)
+			}
+		}
+	}
+}
+
+func TestSharpnessDropsWhenBlurred(t *testing.T) {
+	pgm := noisePGM(32, 32)
+	sharp := pgm.Sharpness()
+	pgm.GaussianBlur(2)
+	blurred := pgm.Sharpness()
+
+	if blurred >= sharp {
+		t.Errorf("Sharpness() after blurring = %v, want less than %v", blurred, sharp)
+	}
+	if flat := stepPGM(16, 16, 80, 80).Sharpness(); flat != 0 {
+		t.Errorf("Sharpness() of a flat image = %v, want 0", flat)
+	}
+}
+
+// roughness returns the mean squared second difference of data inside the disk of the given radius around its center.
+// Smooth ramps score close to 0 and staircases score higher.
+func roughness(data [][]uint8, radius int) float64 {
+	cy, cx := len(data)/2, len(data[0])/2
+	sum, n := 0.0, 0
+	for y := cy - radius; y <= cy+radius; y++ {
+		for x := cx - radius; x <= cx+radius; x++ {
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) > radius*radius {
+				continue
+			}
+			dxx := float64(data[y][x-1]) - 2*float64(data[y][x]) + float64(data[y][x+1])
+			dyy := float64(data[y-1][x]) - 2*float64(data[y][x]) + float64(data[y+1][x])
+			sum += dxx*dxx + dyy*dyy
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+func TestRotateCropWithInterpolation(t *testing.T) {
+	ramp := func() *PGM {
+		pgm := &PGM{data: make([][]uint8, 33), width: 33, height: 33, magicNumber: "P2", max: 255}
+		for y := range pgm.data {
+			pgm.data[y] = make([]uint8, 33)
+			for x := range pgm.data[y] {
+				pgm.data[y][x] = uint8(7 * x)
+			}
+		}
+		return pgm
+	}
+
+	nearest, bilinear := ramp(), ramp()
+	nearest.RotateCropWith(30, 0, Nearest)
+	bilinear.RotateCropWith(30, 0, Bilinear)
+
+	rn, rb := roughness(nearest.data, 10), roughness(bilinear.data, 10)
+	if rb >= rn {
+		t.Errorf("bilinear roughness = %v, want less than nearest's %v", rb, rn)
+	}
+	// The center pixel stays in place under both modes
+	if nearest.data[16][16] != 112 || bilinear.data[16][16] != 112 {
+		t.Errorf("center = %d (nearest), %d (bilinear), want 112", nearest.data[16][16], bilinear.data[16][16])
+	}
+}
+
+func TestGrayDilateFillsDarkPixel(t *testing.T) {
+	pgm := stepPGM(7, 7, 200, 200)
+	pgm.data[3][3] = 10
+	pgm.data[0][6] = 250
+	pgm.GrayDilate(1)
+
+	if v := pgm.data[3][3]; v != 200 {
+		t.Errorf("dark pixel = %d after dilation, want 200 from its neighbors", v)
+	}
+	// The bright corner spreads to its 3×3 neighborhood only
+	for _, p := range []Point{{5, 0}, {5, 1}, {6, 1}} {
+		if v := pgm.data[p.Y][p.X]; v != 250 {
+			t.Errorf("pixel %v = %d, want 250", p, v)
+		}
+	}
+	if v := pgm.data[2][6]; v != 200 {
+		t.Errorf("pixel (6, 2) = %d, want 200", v)
+	}
+}
+
+func TestTopHatIsolatesSpot(t *testing.T) {
+	// A bright 3×3 spot on a horizontal gradient
+	pgm := &PGM{data: make([][]uint8, 32), width: 32, height: 32, magicNumber: "P2", max: 255}
+	for y := range pgm.data {
+		pgm.data[y] = make([]uint8, 32)
+		for x := range pgm.data[y] {
+			pgm.data[y][x] = uint8(4 * x)
+			if x >= 15 && x <= 17 && y >= 15 && y <= 17 {
+				pgm.data[y][x] += 100
+			}
+		}
+	}
+	pgm.TopHat(3)
+
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := pgm.data[y][x]
+			if x >= 15 && x <= 17 && y >= 15 && y <= 17 {
+				if v < 90 {
+					t.Errorf("spot pixel (%d, %d) = %d, want about 100", x, y, v)
+				}
+			} else if x <= 28 && v != 0 || v > 12 {
+				// Only the last columns keep a small residue, where the window is cut by the edge
+				t.Errorf("background pixel (%d, %d) = %d, want the gradient removed", x, y, v)
+			}
+		}
+	}
+}
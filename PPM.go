@@ -2,12 +2,18 @@ package Netpbm
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
+	"image/jpeg"
 	"image/png"
+	"io"
 	"math"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
 )
 
 // PPM represents a Portable PixMap image.
@@ -24,6 +30,7 @@ type Pixel struct {
 }
 
 // ReadPPM reads a PPM image from a file and returns a struct that represents the image.
+// Only the ASCII (P3) format is supported; binary (P6) files are rejected with ErrUnsupportedFormat.
 func ReadPPM(filename string) (*PPM, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -31,24 +38,61 @@ func ReadPPM(filename string) (*PPM, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return decodePPM(file)
+}
+
+// decodePPM reads a P3 PPM image from r.
+func decodePPM(r io.Reader) (*PPM, error) {
+	reader := bufio.NewReader(r)
 	ppm := &PPM{}
 
 	// Read and parse header
-	scanner.Scan()
-	ppm.magicNumber = scanner.Text()
-	scanner.Scan()
-	fmt.Sscanf(scanner.Text(), "%d %d", &ppm.width, &ppm.height)
-	scanner.Scan()
-	fmt.Sscanf(scanner.Text(), "%d", &ppm.max)
-
-	// Read pixel data
+	var err error
+	ppm.magicNumber, err = readToken(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading magic number: %v", ErrInvalidHeader, err)
+	}
+	if ppm.magicNumber == "P6" {
+		return nil, fmt.Errorf("%w: binary P6 data is not supported", ErrUnsupportedFormat)
+	}
+	if ppm.magicNumber != "P3" {
+		return nil, fmt.Errorf("%w: invalid magic number: %s", ErrUnsupportedFormat, ppm.magicNumber)
+	}
+	ppm.width, ppm.height, err = readDimensions(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid dimensions: %v", ErrInvalidHeader, err)
+	}
+	if ppm.width <= 0 || ppm.height <= 0 {
+		return nil, fmt.Errorf("%w: width and height must be positive", ErrInvalidHeader)
+	}
+	maxValue, err := readToken(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading max value: %v", ErrInvalidHeader, err)
+	}
+	if _, err := fmt.Sscanf(maxValue, "%d", &ppm.max); err != nil {
+		return nil, fmt.Errorf("%w: invalid max value: %v", ErrInvalidHeader, err)
+	}
+	if ppm.max < 1 {
+		return nil, fmt.Errorf("%w: max value must be at least 1", ErrInvalidHeader)
+	}
+
+	// Read pixel data, which may be laid out with any whitespace between samples
 	ppm.data = make([][]Pixel, ppm.height)
 	for i := 0; i < ppm.height; i++ {
 		ppm.data[i] = make([]Pixel, ppm.width)
 		for j := 0; j < ppm.width; j++ {
-			scanner.Scan()
-			fmt.Sscanf(scanner.Text(), "%d %d %d", &ppm.data[i][j].R, &ppm.data[i][j].G, &ppm.data[i][j].B)
+			for c := 0; c < 3; c++ {
+				token, err := readToken(reader)
+				if err != nil {
+					if err == io.EOF {
+						return nil, fmt.Errorf("%w at row %d", ErrTruncated, i)
+					}
+					return nil, fmt.Errorf("error reading pixel data at row %d: %v", i, err)
+				}
+				if _, err := fmt.Sscanf(token, "%d", ppm.data[i][j].channel(c)); err != nil {
+					return nil, fmt.Errorf("error parsing pixel value at row %d, column %d: %v", i, j, err)
+				}
+			}
 		}
 	}
 
@@ -65,20 +109,72 @@ func (ppm *PPM) At(x, y int) Pixel {
 	return ppm.data[y][x]
 }
 
-// Set sets the value of the pixel at (x, y).
+// Set sets the value of the pixel at (x, y). Points outside the image are ignored.
 func (ppm *PPM) Set(x, y int, value Pixel) {
-	ppm.data[y][x] = value
+	if x >= 0 && x < ppm.width && y >= 0 && y < ppm.height {
+		ppm.data[y][x] = value
+	}
+}
+
+// SetMany sets every listed pixel to the given color. Points outside the image are ignored.
+func (ppm *PPM) SetMany(points []Point, color Pixel) {
+	for _, p := range points {
+		if p.X >= 0 && p.X < ppm.width && p.Y >= 0 && p.Y < ppm.height {
+			ppm.data[p.Y][p.X] = color
+		}
+	}
+}
+
+// SetRow replaces the pixels of row y with colors, which must hold exactly width pixels.
+func (ppm *PPM) SetRow(y int, colors []Pixel) error {
+	if y < 0 || y >= ppm.height {
+		return fmt.Errorf("row %d out of range", y)
+	}
+	if len(colors) != ppm.width {
+		return fmt.Errorf("expected %d pixels for row %d, got %d", ppm.width, y, len(colors))
+	}
+	copy(ppm.data[y], colors)
+	return nil
+}
+
+// Validate checks that the pixel data matches the width and height of the image and that no sample exceeds the max value.
+func (ppm *PPM) Validate() error {
+	if len(ppm.data) != ppm.height {
+		return fmt.Errorf("invalid data: expected %d rows, got %d", ppm.height, len(ppm.data))
+	}
+	for y, row := range ppm.data {
+		if len(row) != ppm.width {
+			return fmt.Errorf("invalid data: expected %d pixels at row %d, got %d", ppm.width, y, len(row))
+		}
+		for x, p := range row {
+			if uint(p.R) > ppm.max || uint(p.G) > ppm.max || uint(p.B) > ppm.max {
+				return fmt.Errorf("invalid pixel value (%d %d %d) at row %d, column %d: exceeds max value %d", p.R, p.G, p.B, y, x, ppm.max)
+			}
+		}
+	}
+	return nil
 }
 
 // Save saves the PPM image to a file and returns an error if there was a problem.
+// Pixels are written one per line.
 func (ppm *PPM) Save(filename string) error {
+	return ppm.SaveWithOptions(filename, ASCIIOptions{Layout: LayoutPixel, MaxLineWidth: maxLineWidth})
+}
+
+// SaveWithOptions saves the PPM image to a file, laying out the samples as described by opts.
+func (ppm *PPM) SaveWithOptions(filename string, opts ASCIIOptions) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	writer := bufio.NewWriter(file)
+	return ppm.encode(file, opts)
+}
+
+// encode writes the PPM image to w, laying out the samples as described by opts.
+func (ppm *PPM) encode(w io.Writer, opts ASCIIOptions) error {
+	writer := bufio.NewWriter(w)
 
 	// Write header
 	fmt.Fprintf(writer, "%s\n", ppm.magicNumber)
@@ -86,10 +182,23 @@ func (ppm *PPM) Save(filename string) error {
 	fmt.Fprintf(writer, "%d\n", ppm.max)
 
 	// Write pixel data
+	aw := &asciiWriter{w: writer, maxWidth: opts.MaxLineWidth}
 	for i := 0; i < ppm.height; i++ {
 		for j := 0; j < ppm.width; j++ {
-			fmt.Fprintf(writer, "%d %d %d\n", ppm.data[i][j].R, ppm.data[i][j].G, ppm.data[i][j].B)
+			for c := 0; c < 3; c++ {
+				aw.sample(uint(*ppm.data[i][j].channel(c)))
+				if opts.Layout == LayoutSample {
+					aw.newline()
+				}
+			}
+			if opts.Layout == LayoutPixel {
+				aw.newline()
+			}
 		}
+		aw.newline()
+	}
+	if aw.err != nil {
+		return fmt.Errorf("error writing pixel data: %v", aw.err)
 	}
 
 	return writer.Flush()
@@ -199,21 +308,131 @@ type Point struct {
 	X, Y int
 }
 
-// DrawLine draws a line between two points.
+// Rect represents a rectangle in the image, starting at (X, Y) and extending Width pixels right and Height pixels down.
+type Rect struct {
+	X, Y, Width, Height int
+}
+
+// Contains reports whether p lies inside the rectangle. The left and top edges are inside, the right and bottom edges are not.
+func (r Rect) Contains(p Point) bool {
+	return p.X >= r.X && p.X < r.X+r.Width && p.Y >= r.Y && p.Y < r.Y+r.Height
+}
+
+// CircleContains reports whether p lies inside or on the circle with the given center and radius.
+func CircleContains(center Point, radius int, p Point) bool {
+	dx, dy := p.X-center.X, p.Y-center.Y
+	return dx*dx+dy*dy <= radius*radius
+}
+
+// TriangleContains reports whether p lies inside or on the triangle (a, b, c), for any vertex order.
+func TriangleContains(a, b, c, p Point) bool {
+	// edge returns twice the signed area of the triangle (u, v, p)
+	edge := func(u, v Point) int {
+		return (v.X-u.X)*(p.Y-u.Y) - (v.Y-u.Y)*(p.X-u.X)
+	}
+	w1, w2, w3 := edge(b, c), edge(c, a), edge(a, b)
+	if w1+w2+w3 == 0 {
+		// Degenerate triangle: only the segment between its extreme vertices is inside
+		if p.X < min(a.X, b.X, c.X) || p.X > max(a.X, b.X, c.X) || p.Y < min(a.Y, b.Y, c.Y) || p.Y > max(a.Y, b.Y, c.Y) {
+			return false
+		}
+	}
+	return (w1 >= 0 && w2 >= 0 && w3 >= 0) || (w1 <= 0 && w2 <= 0 && w3 <= 0)
+}
+
+// DrawLine draws a line between two points. The part of the line outside the image is skipped.
 func (ppm *PPM) DrawLine(p1, p2 Point, color Pixel) {
+	ppm.walkLine(p1, p2, func(x, y int) {
+		ppm.data[y][x] = color
+	})
+}
+
+// DrawLineAlpha draws a line between two points, blending the color with the existing pixels by the given opacity in [0, 1].
+// It covers the same pixels as DrawLine.
+func (ppm *PPM) DrawLineAlpha(p1, p2 Point, color Pixel, alpha float64) {
+	alpha = math.Max(0, math.Min(alpha, 1))
+	ppm.walkLine(p1, p2, func(x, y int) {
+		ppm.data[y][x] = blendPixel(ppm.data[y][x], color, alpha)
+	})
+}
+
+// walkLine calls plot once for every pixel of the line between two points that lies inside the image.
+func (ppm *PPM) walkLine(p1, p2 Point, plot func(x, y int)) {
 	dx := float64(p2.X - p1.X)
 	dy := float64(p2.Y - p1.Y)
 	steps := int(math.Max(math.Abs(dx), math.Abs(dy)))
+	if steps == 0 {
+		if p1.X >= 0 && p1.X < ppm.width && p1.Y >= 0 && p1.Y < ppm.height {
+			plot(p1.X, p1.Y)
+		}
+		return
+	}
 
 	xIncrement := dx / float64(steps)
 	yIncrement := dy / float64(steps)
 
-	x, y := float64(p1.X), float64(p1.Y)
+	// Only walk the steps that can land inside the image
+	lo, hi := 0.0, 1.0
+	clip := func(start, delta, size float64) {
+		if delta == 0 {
+			if start < 0 || start >= size {
+				lo, hi = 1, 0
+			}
+			return
+		}
+		t1, t2 := (-1-start)/delta, (size-start)/delta
+		lo, hi = math.Max(lo, math.Min(t1, t2)), math.Min(hi, math.Max(t1, t2))
+	}
+	clip(float64(p1.X), dx, float64(ppm.width))
+	clip(float64(p1.Y), dy, float64(ppm.height))
+	if lo > hi {
+		return
+	}
+
+	for i := max(int(math.Floor(lo*float64(steps))), 0); i <= min(int(math.Ceil(hi*float64(steps))), steps); i++ {
+		x := int(float64(p1.X) + float64(i)*xIncrement)
+		y := int(float64(p1.Y) + float64(i)*yIncrement)
+		if x >= 0 && x < ppm.width && y >= 0 && y < ppm.height {
+			plot(x, y)
+		}
+	}
+}
+
+// blendPixel mixes the src color over dst with the given opacity.
+func blendPixel(dst, src Pixel, alpha float64) Pixel {
+	mix := func(d, s uint8) uint8 {
+		return uint8(math.Round(float64(d)*(1-alpha) + float64(s)*alpha))
+	}
+	return Pixel{mix(dst.R, src.R), mix(dst.G, src.G), mix(dst.B, src.B)}
+}
+
+// DrawHorizontalLine draws a horizontal line on row y from x1 to x2, clipped to the image.
+func (ppm *PPM) DrawHorizontalLine(y, x1, x2 int, color Pixel) {
+	if y < 0 || y >= ppm.height {
+		return
+	}
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	x1 = max(x1, 0)
+	x2 = min(x2, ppm.width-1)
+	for x := x1; x <= x2; x++ {
+		ppm.data[y][x] = color
+	}
+}
 
-	for i := 0; i <= steps; i++ {
-		ppm.Set(int(x), int(y), color)
-		x += xIncrement
-		y += yIncrement
+// DrawVerticalLine draws a vertical line on column x from y1 to y2, clipped to the image.
+func (ppm *PPM) DrawVerticalLine(x, y1, y2 int, color Pixel) {
+	if x < 0 || x >= ppm.width {
+		return
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	y1 = max(y1, 0)
+	y2 = min(y2, ppm.height-1)
+	for y := y1; y <= y2; y++ {
+		ppm.data[y][x] = color
 	}
 }
 
@@ -263,6 +482,49 @@ func (ppm *PPM) DrawFilledCircle(center Point, radius int, color Pixel) {
 	}
 }
 
+// DrawFilledSector draws a filled pie slice of the circle of the given radius, sweeping from startDeg to endDeg.
+// Angles are in degrees, measured clockwise from the positive X axis since Y points down, and the slice wraps
+// around 360° when endDeg is less than startDeg. A sweep of 360° or more fills the whole circle.
+func (ppm *PPM) DrawFilledSector(center Point, radius int, startDeg, endDeg float64, color Pixel) {
+	start := math.Mod(startDeg, 360)
+	if start < 0 {
+		start += 360
+	}
+	sweep := endDeg - startDeg
+	if sweep < 0 {
+		sweep = math.Mod(sweep, 360) + 360
+	}
+
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y > radius*radius {
+				continue
+			}
+			angle := math.Atan2(float64(y), float64(x)) * 180 / math.Pi
+			offset := math.Mod(angle-start+720, 360)
+			if sweep >= 360 || (x == 0 && y == 0) || offset <= sweep {
+				ppm.Set(center.X+x, center.Y+y, color)
+			}
+		}
+	}
+}
+
+// DrawCircleAA draws an anti-aliased filled circle. Pixels near the boundary are blended with the existing pixels
+// in proportion to how much of them the circle covers, and pixels outside the image are ignored.
+func (ppm *PPM) DrawCircleAA(center Point, radius int, color Pixel) {
+	r := float64(radius)
+	for y := max(center.Y-radius-1, 0); y <= min(center.Y+radius+1, ppm.height-1); y++ {
+		for x := max(center.X-radius-1, 0); x <= min(center.X+radius+1, ppm.width-1); x++ {
+			// Approximate the coverage by the signed distance of the pixel center to the boundary
+			dist := math.Hypot(float64(x-center.X), float64(y-center.Y))
+			coverage := math.Max(0, math.Min(r+0.5-dist, 1))
+			if coverage > 0 {
+				ppm.data[y][x] = blendPixel(ppm.data[y][x], color, coverage)
+			}
+		}
+	}
+}
+
 // DrawTriangle draws a triangle.
 func (ppm *PPM) DrawTriangle(p1, p2, p3 Point, color Pixel) {
 	ppm.DrawLine(p1, p2, color)
@@ -270,35 +532,25 @@ func (ppm *PPM) DrawTriangle(p1, p2, p3 Point, color Pixel) {
 	ppm.DrawLine(p3, p1, color)
 }
 
-// DrawFilledTriangle draws a filled triangle.
+// DrawFilledTriangle draws a filled triangle, clipped to the image.
 func (ppm *PPM) DrawFilledTriangle(p1, p2, p3 Point, color Pixel) {
-	// Sort points by Y coordinate
-	points := []Point{p1, p2, p3}
-	for i := 0; i < 2; i++ {
-		for j := i + 1; j < 3; j++ {
-			if points[i].Y > points[j].Y {
-				points[i], points[j] = points[j], points[i]
-			}
-		}
-	}
-
-	// Calculate slopes
-	slope1 := float64(points[1].X-points[0].X) / float64(points[1].Y-points[0].Y)
-	slope2 := float64(points[2].X-points[0].X) / float64(points[2].Y-points[0].Y)
-	slope3 := float64(points[2].X-points[1].X) / float64(points[2].Y-points[1].Y)
+	ppm.FillTriangle(p1, p2, p3, color)
+}
 
-	// Draw upper part of the triangle
-	for y := points[0].Y; y <= points[1].Y; y++ {
-		x1 := int(float64(points[0].X) + slope1*float64(y-points[0].Y))
-		x2 := int(float64(points[0].X) + slope2*float64(y-points[0].Y))
-		ppm.DrawLine(Point{x1, y}, Point{x2, y}, color)
-	}
+// FillTriangle draws a filled triangle by testing every pixel of its bounding box with barycentric coordinates.
+// It works for any vertex order, and degenerate triangles are drawn as lines or points.
+func (ppm *PPM) FillTriangle(p1, p2, p3 Point, color Pixel) {
+	minX := max(min(p1.X, p2.X, p3.X), 0)
+	maxX := min(max(p1.X, p2.X, p3.X), ppm.width-1)
+	minY := max(min(p1.Y, p2.Y, p3.Y), 0)
+	maxY := min(max(p1.Y, p2.Y, p3.Y), ppm.height-1)
 
-	// Draw lower part of the triangle
-	for y := points[1].Y + 1; y <= points[2].Y; y++ {
-		x1 := int(float64(points[1].X) + slope3*float64(y-points[1].Y))
-		x2 := int(float64(points[0].X) + slope2*float64(y-points[0].Y))
-		ppm.DrawLine(Point{x1, y}, Point{x2, y}, color)
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			if TriangleContains(p1, p2, p3, Point{x, y}) {
+				ppm.data[y][x] = color
+			}
+		}
 	}
 }
 
@@ -358,6 +610,70 @@ func (ppm *PPM) DrawFilledPolygon(points []Point, color Pixel) {
 	}
 }
 
+// WindingRule decides which parts of a self-intersecting polygon are inside.
+type WindingRule int
+
+const (
+	// EvenOdd fills the points crossed by an odd number of edges when going out of the polygon.
+	EvenOdd WindingRule = iota
+	// NonZero fills the points around which the polygon winds at least once.
+	NonZero
+)
+
+// DrawFilledPolygonRule draws a filled polygon, using rule to decide which pixels are inside.
+// A pixel is tested at its center and pixels outside the image are skipped.
+func (ppm *PPM) DrawFilledPolygonRule(points []Point, color Pixel, rule WindingRule) {
+	if len(points) < 3 {
+		return
+	}
+
+	minY, maxY := points[0].Y, points[0].Y
+	for _, p := range points {
+		minY, maxY = min(minY, p.Y), max(maxY, p.Y)
+	}
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+
+	for y := max(minY, 0); y <= min(maxY, ppm.height-1); y++ {
+		// Find where the edges cross the horizontal line through the pixel centers
+		cy := float64(y) + 0.5
+		var crossings []crossing
+		for i := range points {
+			a, b := points[i], points[(i+1)%len(points)]
+			dir := 1
+			if a.Y > b.Y {
+				a, b = b, a
+				dir = -1
+			}
+			if cy < float64(a.Y) || cy >= float64(b.Y) {
+				continue
+			}
+			x := float64(a.X) + (cy-float64(a.Y))*float64(b.X-a.X)/float64(b.Y-a.Y)
+			crossings = append(crossings, crossing{x, dir})
+		}
+		sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+
+		// Fill the spans between crossings where the winding number is inside
+		winding := 0
+		for i := 0; i < len(crossings)-1; i++ {
+			winding += crossings[i].dir
+			inside := winding != 0
+			if rule == EvenOdd {
+				inside = (i+1)%2 == 1
+			}
+			if !inside {
+				continue
+			}
+			x1 := int(math.Ceil(crossings[i].x - 0.5))
+			x2 := int(math.Ceil(crossings[i+1].x-0.5)) - 1
+			ppm.DrawHorizontalLine(y, x1, x2, color)
+		}
+	}
+}
+
 // ToImage converts the PPM image to the Go image.Image interface.
 func (ppm *PPM) ToImage() image.Image {
 	img := image.NewRGBA(image.Rect(0, 0, ppm.width, ppm.height))
@@ -384,3 +700,1218 @@ func (ppm *PPM) SavePNG(filename string) error {
 
 	return png.Encode(file, img)
 }
+
+func init() {
+	image.RegisterFormat("ppm", "P3", decodePPMImage, DecodeConfigPPM)
+}
+
+// decodePPMImage decodes a P3 PPM image for image.Decode.
+func decodePPMImage(r io.Reader) (image.Image, error) {
+	ppm, err := decodePPM(r)
+	if err != nil {
+		return nil, err
+	}
+	return ppm.ToImage(), nil
+}
+
+// DecodeConfigPPM returns the dimensions and color model of a PPM image without reading its pixel data.
+// It is registered with the image package for P3 files, so image.DecodeConfig and image.Decode recognize them
+// once this package is imported.
+func DecodeConfigPPM(r io.Reader) (image.Config, error) {
+	var tokens [4]string
+	for i := range tokens {
+		token, err := readToken(r)
+		if err != nil {
+			return image.Config{}, fmt.Errorf("%w: error reading header: %v", ErrInvalidHeader, err)
+		}
+		tokens[i] = token
+	}
+
+	if tokens[0] != "P3" && tokens[0] != "P6" {
+		return image.Config{}, fmt.Errorf("%w: invalid magic number: %s", ErrUnsupportedFormat, tokens[0])
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(tokens[1]+" "+tokens[2], "%d %d", &width, &height); err != nil {
+		return image.Config{}, fmt.Errorf("%w: invalid dimensions: %v", ErrInvalidHeader, err)
+	}
+	if width <= 0 || height <= 0 {
+		return image.Config{}, fmt.Errorf("%w: width and height must be positive", ErrInvalidHeader)
+	}
+
+	var max uint
+	if _, err := fmt.Sscanf(tokens[3], "%d", &max); err != nil {
+		return image.Config{}, fmt.Errorf("%w: invalid max value: %v", ErrInvalidHeader, err)
+	}
+	if max < 1 {
+		return image.Config{}, fmt.Errorf("%w: max value must be at least 1", ErrInvalidHeader)
+	}
+
+	return image.Config{ColorModel: color.RGBAModel, Width: width, Height: height}, nil
+}
+
+// GammaCorrectResize resizes the PPM image to w×h by averaging the source pixels in linear light.
+// Samples are decoded with the given gamma (2.2 for sRGB), averaged, then encoded again.
+func (ppm *PPM) GammaCorrectResize(w, h int, gamma float64) {
+	if w <= 0 || h <= 0 || gamma <= 0 || ppm.max == 0 || ppm.width <= 0 || ppm.height <= 0 {
+		return
+	}
+
+	// Decode every sample to linear light once
+	maxValue := float64(ppm.max)
+	var lut [256]float64
+	for i := range lut {
+		lut[i] = math.Pow(float64(i)/maxValue, gamma)
+	}
+	encode := func(v float64) uint8 {
+		return uint8(math.Round(math.Min(math.Pow(v, 1/gamma)*maxValue, maxValue)))
+	}
+
+	newData := make([][]Pixel, h)
+	for y := 0; y < h; y++ {
+		newData[y] = make([]Pixel, w)
+		y0 := y * ppm.height / h
+		y1 := max((y+1)*ppm.height/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * ppm.width / w
+			x1 := max((x+1)*ppm.width/w, x0+1)
+
+			var r, g, b float64
+			for i := y0; i < y1; i++ {
+				for j := x0; j < x1; j++ {
+					r += lut[ppm.data[i][j].R]
+					g += lut[ppm.data[i][j].G]
+					b += lut[ppm.data[i][j].B]
+				}
+			}
+			n := float64((y1 - y0) * (x1 - x0))
+			newData[y][x] = Pixel{encode(r / n), encode(g / n), encode(b / n)}
+		}
+	}
+
+	ppm.data = newData
+	ppm.width, ppm.height = w, h
+}
+
+// InvertChannel inverts a single channel of the PPM image, where 0, 1 and 2 select red, green and blue.
+func (ppm *PPM) InvertChannel(channel int) error {
+	if channel < 0 || channel > 2 {
+		return fmt.Errorf("invalid channel: %d", channel)
+	}
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			sample := ppm.data[i][j].channel(channel)
+			*sample = uint8(ppm.max) - *sample
+		}
+	}
+	return nil
+}
+
+// channel returns a pointer to the red, green or blue component of the pixel.
+func (p *Pixel) channel(c int) *uint8 {
+	switch c {
+	case 0:
+		return &p.R
+	case 1:
+		return &p.G
+	default:
+		return &p.B
+	}
+}
+
+// SwapChannels reorders the channels of the PPM image. The order is a permutation of "RGB",
+// e.g. "BGR" exchanges the red and blue channels.
+func (ppm *PPM) SwapChannels(order string) error {
+	if len(order) != 3 {
+		return fmt.Errorf("invalid channel order: %s", order)
+	}
+	var source [3]int
+	var seen [3]bool
+	for i := 0; i < 3; i++ {
+		c := strings.IndexByte("RGB", order[i])
+		if c < 0 || seen[c] {
+			return fmt.Errorf("invalid channel order: %s", order)
+		}
+		seen[c] = true
+		source[i] = c
+	}
+
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			old := ppm.data[i][j]
+			ppm.data[i][j] = Pixel{*old.channel(source[0]), *old.channel(source[1]), *old.channel(source[2])}
+		}
+	}
+	return nil
+}
+
+// WhiteBalance multiplies each channel of the PPM image by its gain, clamping the result to [0, max].
+func (ppm *PPM) WhiteBalance(rGain, gGain, bGain float64) {
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			p := &ppm.data[i][j]
+			p.R = clampSample(float64(p.R)*rGain, ppm.max)
+			p.G = clampSample(float64(p.G)*gGain, ppm.max)
+			p.B = clampSample(float64(p.B)*bGain, ppm.max)
+		}
+	}
+}
+
+// clampSample rounds v to the nearest integer in [0, max].
+func clampSample(v float64, max uint) uint8 {
+	if v <= 0 || math.IsNaN(v) {
+		return 0
+	}
+	if v >= float64(max) {
+		return uint8(max)
+	}
+	return uint8(math.Round(v))
+}
+
+// AdjustSaturation scales the saturation of the PPM image by moving each channel away from
+// (factor > 1) or towards (factor < 1) the luma of the pixel. A factor of 0 gives a gray image.
+func (ppm *PPM) AdjustSaturation(factor float64) {
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			p := &ppm.data[i][j]
+			luma := 0.299*float64(p.R) + 0.587*float64(p.G) + 0.114*float64(p.B)
+			p.R = clampSample(luma+factor*(float64(p.R)-luma), ppm.max)
+			p.G = clampSample(luma+factor*(float64(p.G)-luma), ppm.max)
+			p.B = clampSample(luma+factor*(float64(p.B)-luma), ppm.max)
+		}
+	}
+}
+
+// Desaturate replaces each pixel of the PPM image by its luma, keeping the image in color format.
+func (ppm *PPM) Desaturate() {
+	ppm.AdjustSaturation(0)
+}
+
+// RotateHue rotates the hue of every pixel of the PPM image by the given angle in degrees.
+func (ppm *PPM) RotateHue(degrees float64) {
+	if ppm.max == 0 {
+		return
+	}
+	maxValue := float64(ppm.max)
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			p := &ppm.data[i][j]
+			h, s, v := rgbToHSV(float64(p.R)/maxValue, float64(p.G)/maxValue, float64(p.B)/maxValue)
+			h = math.Mod(h+degrees, 360)
+			if h < 0 {
+				h += 360
+			}
+			r, g, b := hsvToRGB(h, s, v)
+			p.R = clampSample(r*maxValue, ppm.max)
+			p.G = clampSample(g*maxValue, ppm.max)
+			p.B = clampSample(b*maxValue, ppm.max)
+		}
+	}
+}
+
+// rgbToHSV converts RGB components in [0, 1] to a hue in [0, 360) and saturation and value in [0, 1].
+func rgbToHSV(r, g, b float64) (h, s, v float64) {
+	v = math.Max(r, math.Max(g, b))
+	delta := v - math.Min(r, math.Min(g, b))
+	if v > 0 {
+		s = delta / v
+	}
+	if delta == 0 {
+		return 0, s, v
+	}
+
+	switch v {
+	case r:
+		h = math.Mod((g-b)/delta, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// hsvToRGB converts a hue in [0, 360) and saturation and value in [0, 1] to RGB components in [0, 1].
+func hsvToRGB(h, s, v float64) (r, g, b float64) {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return r + m, g + m, b + m
+}
+
+// planes returns the red, green and blue samples of the PPM image as floating point values.
+func (ppm *PPM) planes() [3][][]float64 {
+	var planes [3][][]float64
+	for c := range planes {
+		planes[c] = make([][]float64, ppm.height)
+		for i := 0; i < ppm.height; i++ {
+			planes[c][i] = make([]float64, ppm.width)
+			for j := 0; j < ppm.width; j++ {
+				planes[c][i][j] = float64(*ppm.data[i][j].channel(c))
+			}
+		}
+	}
+	return planes
+}
+
+// setPlanes replaces the samples of the PPM image, rounding and clamping them to [0, max].
+func (ppm *PPM) setPlanes(planes [3][][]float64) {
+	for c := range planes {
+		for i := 0; i < ppm.height; i++ {
+			for j := 0; j < ppm.width; j++ {
+				*ppm.data[i][j].channel(c) = clampSample(planes[c][i][j], ppm.max)
+			}
+		}
+	}
+}
+
+// GaussianBlur blurs the PPM image with a Gaussian of the given standard deviation.
+func (ppm *PPM) GaussianBlur(sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+	kernel := gaussianKernel(sigma)
+	planes := ppm.planes()
+	for c := range planes {
+		planes[c] = convolvePlane(planes[c], kernel, kernel)
+	}
+	ppm.setPlanes(planes)
+}
+
+// ConvolveSeparable convolves the PPM image with the kernel formed by the outer product of vert and horiz,
+// as a horizontal then a vertical 1D pass on each channel, and divides the result by divisor (treated as 1 when zero).
+// Samples outside the image are clamped to the nearest edge.
+func (ppm *PPM) ConvolveSeparable(horiz, vert []float64, divisor float64) {
+	if len(horiz) == 0 || len(vert) == 0 {
+		return
+	}
+	vert = scaledKernel(vert, divisor)
+	planes := ppm.planes()
+	for c := range planes {
+		planes[c] = convolvePlane(planes[c], horiz, vert)
+	}
+	ppm.setPlanes(planes)
+}
+
+// GaussianBlurParallel is like GaussianBlur but splits the rows of each pass between GOMAXPROCS goroutines.
+// The result is identical to GaussianBlur.
+func (ppm *PPM) GaussianBlurParallel(sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+	kernel := gaussianKernel(sigma)
+	planes := ppm.planes()
+	for c := range planes {
+		planes[c] = convolvePlaneParallel(planes[c], kernel, kernel, runtime.GOMAXPROCS(0))
+	}
+	ppm.setPlanes(planes)
+}
+
+// UnsharpMask sharpens the PPM image by adding amount times the difference between the image and its Gaussian blur.
+func (ppm *PPM) UnsharpMask(sigma float64, amount float64) {
+	if sigma <= 0 {
+		return
+	}
+	kernel := gaussianKernel(sigma)
+	planes := ppm.planes()
+	for c := range planes {
+		planes[c] = unsharpPlane(planes[c], convolvePlane(planes[c], kernel, kernel), amount)
+	}
+	ppm.setPlanes(planes)
+}
+
+// CountIf returns the number of pixels of the PPM image that satisfy pred.
+func (ppm *PPM) CountIf(pred func(Pixel) bool) int {
+	count := 0
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			if pred(ppm.data[i][j]) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Stats returns the per-channel minimum, maximum, mean and standard deviation of the PPM image, computed in a single pass.
+// The mean and standard deviation arrays are indexed by channel: 0 for red, 1 for green and 2 for blue.
+func (ppm *PPM) Stats() (min, max Pixel, mean, stddev [3]float64) {
+	if ppm.width <= 0 || ppm.height <= 0 {
+		return min, max, mean, stddev
+	}
+
+	min = Pixel{255, 255, 255}
+	var sum, sumSquares [3]float64
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			p := ppm.data[i][j]
+			for c := 0; c < 3; c++ {
+				value := *p.channel(c)
+				if value < *min.channel(c) {
+					*min.channel(c) = value
+				}
+				if value > *max.channel(c) {
+					*max.channel(c) = value
+				}
+				sum[c] += float64(value)
+				sumSquares[c] += float64(value) * float64(value)
+			}
+		}
+	}
+
+	n := float64(ppm.width * ppm.height)
+	for c := 0; c < 3; c++ {
+		mean[c] = sum[c] / n
+		stddev[c] = math.Sqrt(math.Max(sumSquares[c]/n-mean[c]*mean[c], 0))
+	}
+	return min, max, mean, stddev
+}
+
+// newPPM returns a black PPM image with the given dimensions, magic number and max value.
+func newPPM(width, height int, magicNumber string, max uint) *PPM {
+	ppm := &PPM{
+		data:        make([][]Pixel, height),
+		width:       width,
+		height:      height,
+		magicNumber: magicNumber,
+		max:         max,
+	}
+	for i := range ppm.data {
+		ppm.data[i] = make([]Pixel, width)
+	}
+	return ppm
+}
+
+// Tile returns a new PPM image that repeats the image cols times horizontally and rows times vertically.
+// It returns nil if cols or rows is not positive.
+func (ppm *PPM) Tile(cols, rows int) *PPM {
+	if cols <= 0 || rows <= 0 {
+		return nil
+	}
+
+	tiled := newPPM(ppm.width*cols, ppm.height*rows, ppm.magicNumber, ppm.max)
+	for i := 0; i < tiled.height; i++ {
+		for j := 0; j < tiled.width; j++ {
+			tiled.data[i][j] = ppm.data[i%ppm.height][j%ppm.width]
+		}
+	}
+	return tiled
+}
+
+// Montage arranges the images in a grid of cols columns, left to right then top to bottom, with gap pixels between cells.
+// Every cell is as large as the largest image, each image is placed at the top-left corner of its cell and the rest is filled with bg.
+func Montage(images []*PPM, cols int, gap int, bg Pixel) (*PPM, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images to montage")
+	}
+	if cols <= 0 {
+		return nil, fmt.Errorf("invalid number of columns: %d", cols)
+	}
+	if gap < 0 {
+		return nil, fmt.Errorf("invalid gap: %d", gap)
+	}
+
+	cellWidth, cellHeight := 0, 0
+	var maxValue uint
+	for i, img := range images {
+		if img == nil {
+			return nil, fmt.Errorf("image %d is nil", i)
+		}
+		cellWidth = max(cellWidth, img.width)
+		cellHeight = max(cellHeight, img.height)
+		maxValue = max(maxValue, img.max)
+	}
+
+	cols = min(cols, len(images))
+	rows := (len(images) + cols - 1) / cols
+	montage := newPPM(cols*cellWidth+(cols-1)*gap, rows*cellHeight+(rows-1)*gap, images[0].magicNumber, maxValue)
+	montage.DrawFilledRectangle(Point{0, 0}, montage.width, montage.height, bg)
+
+	for n, img := range images {
+		x0 := (n % cols) * (cellWidth + gap)
+		y0 := (n / cols) * (cellHeight + gap)
+		for i := 0; i < img.height; i++ {
+			copy(montage.data[y0+i][x0:], img.data[i])
+		}
+	}
+	return montage, nil
+}
+
+// IsUniform reports whether all pixels of the PPM image have the same color and, if so, returns that color.
+func (ppm *PPM) IsUniform() (bool, Pixel) {
+	if ppm.width <= 0 || ppm.height <= 0 {
+		return false, Pixel{}
+	}
+	first := ppm.data[0][0]
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			if ppm.data[i][j] != first {
+				return false, Pixel{}
+			}
+		}
+	}
+	return true, first
+}
+
+// ScaleToFit resizes the PPM image to the largest size that fits within maxW×maxH while keeping its aspect ratio,
+// and returns the new dimensions.
+func (ppm *PPM) ScaleToFit(maxW, maxH int) (int, int) {
+	if maxW <= 0 || maxH <= 0 || ppm.width <= 0 || ppm.height <= 0 {
+		return ppm.width, ppm.height
+	}
+
+	scale := math.Min(float64(maxW)/float64(ppm.width), float64(maxH)/float64(ppm.height))
+	w := min(max(int(math.Round(float64(ppm.width)*scale)), 1), maxW)
+	h := min(max(int(math.Round(float64(ppm.height)*scale)), 1), maxH)
+
+	// A gamma of 1 averages the encoded samples directly
+	ppm.GammaCorrectResize(w, h, 1)
+	return ppm.width, ppm.height
+}
+
+// Average returns a new PPM image where each pixel is the rounded mean of the corresponding pixels of the images.
+// All images must have the same dimensions.
+func Average(images []*PPM) (*PPM, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images to average")
+	}
+	for i, img := range images {
+		if img == nil {
+			return nil, fmt.Errorf("image %d is nil", i)
+		}
+		if img.width != images[0].width || img.height != images[0].height {
+			return nil, fmt.Errorf("size mismatch: image %d is %dx%d, expected %dx%d", i, img.width, img.height, images[0].width, images[0].height)
+		}
+	}
+
+	first := images[0]
+	avg := newPPM(first.width, first.height, first.magicNumber, first.max)
+	n := uint(len(images))
+	for i := 0; i < avg.height; i++ {
+		for j := 0; j < avg.width; j++ {
+			var r, g, b uint
+			for _, img := range images {
+				r += uint(img.data[i][j].R)
+				g += uint(img.data[i][j].G)
+				b += uint(img.data[i][j].B)
+			}
+			avg.data[i][j] = Pixel{uint8((r + n/2) / n), uint8((g + n/2) / n), uint8((b + n/2) / n)}
+		}
+	}
+	return avg, nil
+}
+
+// FillBackground sets every pixel that still has the sentinel color to color, leaving drawn pixels untouched.
+// Start from a canvas filled with a sentinel color that the drawing does not use.
+func (ppm *PPM) FillBackground(sentinel, color Pixel) {
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			if ppm.data[i][j] == sentinel {
+				ppm.data[i][j] = color
+			}
+		}
+	}
+}
+
+// RotateCrop rotates the PPM image clockwise by angle degrees around its center, keeping its dimensions.
+// Corners rotated out of the canvas are cropped and uncovered pixels are set to background.
+func (ppm *PPM) RotateCrop(angle float64, background Pixel) {
+	ppm.RotateCropWith(angle, background, Nearest)
+}
+
+// RotateCropWith is like RotateCrop but samples the source image with the given interpolation.
+func (ppm *PPM) RotateCropWith(angle float64, background Pixel, interp Interpolation) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	cx, cy := float64(ppm.width-1)/2, float64(ppm.height-1)/2
+
+	newData := make([][]Pixel, ppm.height)
+	for i := 0; i < ppm.height; i++ {
+		newData[i] = make([]Pixel, ppm.width)
+		for j := 0; j < ppm.width; j++ {
+			// Map the destination pixel back to the source image
+			dx, dy := float64(j)-cx, float64(i)-cy
+			sx := cx + dx*cos + dy*sin
+			sy := cy - dx*sin + dy*cos
+			if !insideRounded(sx, sy, ppm.width, ppm.height) {
+				newData[i][j] = background
+			} else if interp == Bilinear {
+				newData[i][j] = ppm.AtBilinear(sx, sy)
+			} else {
+				newData[i][j] = ppm.data[int(math.Round(sy))][int(math.Round(sx))]
+			}
+		}
+	}
+
+	ppm.data = newData
+}
+
+// AtBilinear returns the color at (x, y) interpolated between the four nearest pixels.
+// Coordinates outside the image are clamped to its edges.
+func (ppm *PPM) AtBilinear(x, y float64) Pixel {
+	x0, y0, x1, y1, fx, fy := bilinearWeights(x, y, ppm.width, ppm.height)
+	mix := func(c int) uint8 {
+		top := float64(*ppm.data[y0][x0].channel(c))*(1-fx) + float64(*ppm.data[y0][x1].channel(c))*fx
+		bottom := float64(*ppm.data[y1][x0].channel(c))*(1-fx) + float64(*ppm.data[y1][x1].channel(c))*fx
+		return uint8(math.Round(top*(1-fy) + bottom*fy))
+	}
+	return Pixel{mix(0), mix(1), mix(2)}
+}
+
+// AutoLevels stretches each channel of the PPM image to the full [0, max] range.
+// The black and white points of a channel are chosen so that about clipPercent percent of the pixels
+// are clipped at each end of its histogram.
+func (ppm *PPM) AutoLevels(clipPercent float64) {
+	total := ppm.width * ppm.height
+	if total == 0 {
+		return
+	}
+	clip := int(float64(total) * math.Max(clipPercent, 0) / 100)
+
+	var lut [3][256]uint8
+	for c := 0; c < 3; c++ {
+		var histogram [256]int
+		for i := 0; i < ppm.height; i++ {
+			for j := 0; j < ppm.width; j++ {
+				histogram[*ppm.data[i][j].channel(c)]++
+			}
+		}
+
+		// Find the black point from the dark end and the white point from the bright end
+		low, count := 0, 0
+		for ; low < 255; low++ {
+			count += histogram[low]
+			if count > clip {
+				break
+			}
+		}
+		high, count := 255, 0
+		for ; high > 0; high-- {
+			count += histogram[high]
+			if count > clip {
+				break
+			}
+		}
+
+		for v := range lut[c] {
+			if high <= low {
+				lut[c][v] = uint8(v)
+				continue
+			}
+			lut[c][v] = clampSample(float64(v-low)*float64(ppm.max)/float64(high-low), ppm.max)
+		}
+	}
+
+	ppm.ApplyLUT(lut[0], lut[1], lut[2])
+}
+
+// Bytes returns the pixels of the PPM image as tightly packed RGB bytes in row-major order:
+// pixel (x, y) starts at index 3*(y*width+x) and is followed by its green and blue components.
+func (ppm *PPM) Bytes() []byte {
+	data := make([]byte, 0, ppm.width*ppm.height*3)
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			data = append(data, ppm.data[i][j].R, ppm.data[i][j].G, ppm.data[i][j].B)
+		}
+	}
+	return data
+}
+
+// PPMFromBytes builds a PPM image from tightly packed RGB bytes in the layout returned by Bytes.
+// The max value must be in [1, 255] and no sample may exceed it.
+func PPMFromBytes(data []byte, width, height int, max uint) (*PPM, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid dimensions: width and height must be positive")
+	}
+	if max < 1 || max > 255 {
+		return nil, fmt.Errorf("invalid max value: %d", max)
+	}
+	if len(data) != width*height*3 {
+		return nil, fmt.Errorf("invalid data length: expected %d bytes, got %d", width*height*3, len(data))
+	}
+
+	ppm := newPPM(width, height, "P3", max)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			k := 3 * (i*width + j)
+			ppm.data[i][j] = Pixel{data[k], data[k+1], data[k+2]}
+		}
+	}
+	if err := ppm.Validate(); err != nil {
+		return nil, err
+	}
+	return ppm, nil
+}
+
+// Row returns a copy of row y of the PPM image, or nil if y is out of range.
+func (ppm *PPM) Row(y int) []Pixel {
+	if y < 0 || y >= ppm.height {
+		return nil
+	}
+	row := make([]Pixel, ppm.width)
+	copy(row, ppm.data[y])
+	return row
+}
+
+// Column returns a copy of column x of the PPM image, or nil if x is out of range.
+func (ppm *PPM) Column(x int) []Pixel {
+	if x < 0 || x >= ppm.width {
+		return nil
+	}
+	column := make([]Pixel, ppm.height)
+	for i := range column {
+		column[i] = ppm.data[i][x]
+	}
+	return column
+}
+
+// Downsample2x returns a new PPM image of half the size where each pixel is the rounded average of a 2×2 block.
+// For odd dimensions the last row or column is repeated to complete the block.
+func (ppm *PPM) Downsample2x() *PPM {
+	half := newPPM((ppm.width+1)/2, (ppm.height+1)/2, ppm.magicNumber, ppm.max)
+	for i := 0; i < half.height; i++ {
+		y0, y1 := 2*i, min(2*i+1, ppm.height-1)
+		for j := 0; j < half.width; j++ {
+			x0, x1 := 2*j, min(2*j+1, ppm.width-1)
+			a, b, c, d := ppm.data[y0][x0], ppm.data[y0][x1], ppm.data[y1][x0], ppm.data[y1][x1]
+			half.data[i][j] = Pixel{
+				uint8((uint(a.R) + uint(b.R) + uint(c.R) + uint(d.R) + 2) / 4),
+				uint8((uint(a.G) + uint(b.G) + uint(c.G) + uint(d.G) + 2) / 4),
+				uint8((uint(a.B) + uint(b.B) + uint(c.B) + uint(d.B) + 2) / 4),
+			}
+		}
+	}
+	return half
+}
+
+// clone returns a deep copy of the PPM image.
+func (ppm *PPM) clone() *PPM {
+	c := newPPM(ppm.width, ppm.height, ppm.magicNumber, ppm.max)
+	for i := range c.data {
+		copy(c.data[i], ppm.data[i])
+	}
+	return c
+}
+
+// GaussianPyramid returns up to levels images, starting with a copy of the PPM image, where each level is the previous one
+// blurred and halved in size. It stops early once a dimension reaches 1 and returns nil if levels is not positive.
+func (ppm *PPM) GaussianPyramid(levels int) []*PPM {
+	if levels <= 0 {
+		return nil
+	}
+
+	pyramid := []*PPM{ppm.clone()}
+	for len(pyramid) < levels {
+		last := pyramid[len(pyramid)-1]
+		if last.width <= 1 || last.height <= 1 {
+			break
+		}
+		blurred := last.clone()
+		blurred.GaussianBlur(1)
+		pyramid = append(pyramid, blurred.Downsample2x())
+	}
+	return pyramid
+}
+
+// HueCycleFrames returns n copies of base where frame i has its hue rotated by i*360/n degrees.
+// Frame 0 is an exact copy of base. It returns nil if n is not positive.
+func HueCycleFrames(base *PPM, n int) []*PPM {
+	if n <= 0 {
+		return nil
+	}
+
+	frames := make([]*PPM, n)
+	for i := range frames {
+		frames[i] = base.clone()
+		if i > 0 {
+			frames[i].RotateHue(float64(i) * 360 / float64(n))
+		}
+	}
+	return frames
+}
+
+// Clear sets every pixel of the PPM image to color.
+func (ppm *PPM) Clear(color Pixel) {
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			ppm.data[i][j] = color
+		}
+	}
+}
+
+// Reset sets every pixel of the PPM image to black, keeping its dimensions.
+func (ppm *PPM) Reset() {
+	ppm.Clear(Pixel{})
+}
+
+// NewRadialGradient returns a w×h PPM image that goes from inner at center to outer at the farthest corner.
+func NewRadialGradient(w, h int, center Point, inner, outer Pixel) *PPM {
+	ppm := newPPM(max(w, 0), max(h, 0), "P3", 255)
+
+	// Distance from the center to the farthest corner
+	radius := 0.0
+	for _, corner := range []Point{{0, 0}, {w - 1, 0}, {0, h - 1}, {w - 1, h - 1}} {
+		radius = math.Max(radius, math.Hypot(float64(corner.X-center.X), float64(corner.Y-center.Y)))
+	}
+
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			t := 0.0
+			if radius > 0 {
+				t = math.Min(math.Hypot(float64(j-center.X), float64(i-center.Y))/radius, 1)
+			}
+			ppm.data[i][j] = blendPixel(inner, outer, t)
+		}
+	}
+	return ppm
+}
+
+// BlendMode selects how BlendWith combines two images.
+type BlendMode int
+
+const (
+	// BlendNormal replaces the image with the other one.
+	BlendNormal BlendMode = iota
+	// BlendMultiply multiplies the samples, which darkens the image.
+	BlendMultiply
+	// BlendScreen multiplies the inverted samples, which lightens the image.
+	BlendScreen
+	// BlendOverlay multiplies dark samples and screens light samples of the image.
+	BlendOverlay
+)
+
+// BlendWith combines other into the PPM image channel by channel using mode, then mixes the result
+// with the original image by opacity in [0, 1]. Both images must have the same dimensions.
+func (ppm *PPM) BlendWith(other *PPM, mode BlendMode, opacity float64) error {
+	if other == nil {
+		return fmt.Errorf("cannot blend with a nil PPM")
+	}
+	if other.width != ppm.width || other.height != ppm.height {
+		return fmt.Errorf("size mismatch: %dx%d and %dx%d", ppm.width, ppm.height, other.width, other.height)
+	}
+	if mode < BlendNormal || mode > BlendOverlay {
+		return fmt.Errorf("invalid blend mode: %d", mode)
+	}
+	if ppm.max == 0 || other.max == 0 {
+		return fmt.Errorf("invalid max value: 0")
+	}
+	opacity = math.Max(0, math.Min(opacity, 1))
+
+	blend := func(a, b float64) float64 {
+		switch mode {
+		case BlendMultiply:
+			return a * b
+		case BlendScreen:
+			return 1 - (1-a)*(1-b)
+		case BlendOverlay:
+			if a < 0.5 {
+				return 2 * a * b
+			}
+			return 1 - 2*(1-a)*(1-b)
+		default:
+			return b
+		}
+	}
+
+	baseMax, otherMax := float64(ppm.max), float64(other.max)
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			for c := 0; c < 3; c++ {
+				sample := ppm.data[i][j].channel(c)
+				a := float64(*sample) / baseMax
+				b := float64(*other.data[i][j].channel(c)) / otherMax
+				*sample = clampSample((a*(1-opacity)+blend(a, b)*opacity)*baseMax, ppm.max)
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveVerticalSeams shrinks the PPM image by n columns using seam carving: n times, the connected top-to-bottom
+// path of pixels with the lowest Sobel energy is removed, so that low-detail areas shrink before high-detail ones.
+func (ppm *PPM) RemoveVerticalSeams(n int) error {
+	if n < 0 || n >= ppm.width || ppm.height <= 0 {
+		return fmt.Errorf("cannot remove %d seams from a %dx%d image", n, ppm.width, ppm.height)
+	}
+
+	for ; n > 0; n-- {
+		// Energy of each pixel from the gradients of its luma
+		luma := make([][]float64, ppm.height)
+		for i := range luma {
+			luma[i] = make([]float64, ppm.width)
+			for j, p := range ppm.data[i] {
+				luma[i][j] = 0.299*float64(p.R) + 0.587*float64(p.G) + 0.114*float64(p.B)
+			}
+		}
+		gx, gy := sobelPlane(luma)
+
+		// cost[i][j] is the lowest energy of a seam from the top row to (j, i)
+		cost := make([][]float64, ppm.height)
+		for i := range cost {
+			cost[i] = make([]float64, ppm.width)
+			for j := range cost[i] {
+				cost[i][j] = math.Hypot(gx[i][j], gy[i][j])
+				if i > 0 {
+					best := cost[i-1][j]
+					if j > 0 {
+						best = math.Min(best, cost[i-1][j-1])
+					}
+					if j < ppm.width-1 {
+						best = math.Min(best, cost[i-1][j+1])
+					}
+					cost[i][j] += best
+				}
+			}
+		}
+
+		// Trace the seam back from the cheapest pixel of the bottom row
+		seam := make([]int, ppm.height)
+		last := ppm.height - 1
+		for j := range cost[last] {
+			if cost[last][j] < cost[last][seam[last]] {
+				seam[last] = j
+			}
+		}
+		for i := last - 1; i >= 0; i-- {
+			j := seam[i+1]
+			seam[i] = j
+			for _, k := range []int{j - 1, j + 1} {
+				if k >= 0 && k < ppm.width && cost[i][k] < cost[i][seam[i]] {
+					seam[i] = k
+				}
+			}
+		}
+
+		for i, j := range seam {
+			ppm.data[i] = append(ppm.data[i][:j], ppm.data[i][j+1:]...)
+		}
+		ppm.width--
+	}
+	return nil
+}
+
+// Lengths of the tick marks drawn by DrawRuler.
+const (
+	rulerTickLength     = 3
+	rulerLongTickLength = 8
+	rulerLongTickEvery  = 10
+)
+
+// DrawRuler draws tick marks along the top and left edges of the PPM image every majorEvery pixels,
+// with longer ticks every 10 marks.
+func (ppm *PPM) DrawRuler(color Pixel, majorEvery int) {
+	if majorEvery <= 0 {
+		return
+	}
+
+	tickLength := func(pos int) int {
+		if pos%(majorEvery*rulerLongTickEvery) == 0 {
+			return rulerLongTickLength
+		}
+		return rulerTickLength
+	}
+
+	for x := 0; x < ppm.width; x += majorEvery {
+		ppm.DrawVerticalLine(x, 0, tickLength(x)-1, color)
+	}
+	for y := 0; y < ppm.height; y += majorEvery {
+		ppm.DrawHorizontalLine(y, 0, tickLength(y)-1, color)
+	}
+}
+
+// SaveAtomic saves the PPM image like Save, but writes to a temporary file first and renames it over filename,
+// so an error while writing never leaves a partially written file behind.
+func (ppm *PPM) SaveAtomic(filename string) error {
+	return saveAtomic(filename, ppm.Save)
+}
+
+// DrawBitmap sets the pixels of the PPM image to color wherever mask is true, with the top-left corner of the mask at at.
+// Mask pixels falling outside the image are ignored.
+func (ppm *PPM) DrawBitmap(mask *PBM, at Point, color Pixel) {
+	for i := 0; i < mask.height; i++ {
+		for j := 0; j < mask.width; j++ {
+			if mask.data[i][j] {
+				ppm.Set(at.X+j, at.Y+i, color)
+			}
+		}
+	}
+}
+
+// ToPGMLinear converts the PPM image to PGM by computing the luminance in linear light:
+// each channel is decoded from sRGB, weighted with the Rec. 709 coefficients, then encoded back to sRGB.
+// Saturated colors come out brighter than with ToPGM, which weights the encoded values directly.
+func (ppm *PPM) ToPGMLinear() *PGM {
+	pgm := &PGM{
+		data:        make([][]uint8, ppm.height),
+		width:       ppm.width,
+		height:      ppm.height,
+		magicNumber: "P2",
+		max:         ppm.max,
+	}
+
+	maxValue := float64(ppm.max)
+	for i := 0; i < ppm.height; i++ {
+		pgm.data[i] = make([]uint8, ppm.width)
+		for j := 0; j < ppm.width; j++ {
+			p := ppm.data[i][j]
+			r := srgbToLinear(float64(p.R) / maxValue)
+			g := srgbToLinear(float64(p.G) / maxValue)
+			b := srgbToLinear(float64(p.B) / maxValue)
+			pgm.data[i][j] = clampSample(linearToSRGB(0.2126*r+0.7152*g+0.0722*b)*maxValue, ppm.max)
+		}
+	}
+
+	return pgm
+}
+
+// srgbToLinear decodes an sRGB value in [0, 1] to linear light.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB encodes a linear light value in [0, 1] to sRGB.
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// CropToAspect crops the PPM image around its center to the wRatio:hRatio aspect ratio, removing as few pixels as possible.
+func (ppm *PPM) CropToAspect(wRatio, hRatio int) error {
+	if wRatio <= 0 || hRatio <= 0 {
+		return fmt.Errorf("invalid aspect ratio: %d:%d", wRatio, hRatio)
+	}
+	if ppm.width <= 0 || ppm.height <= 0 {
+		return nil
+	}
+
+	w, h := ppm.width, ppm.height
+	if w*hRatio > h*wRatio {
+		// Too wide: keep the full height
+		w = max(h*wRatio/hRatio, 1)
+	} else {
+		// Too tall: keep the full width
+		h = max(w*hRatio/wRatio, 1)
+	}
+
+	x0, y0 := (ppm.width-w)/2, (ppm.height-h)/2
+	newData := make([][]Pixel, h)
+	for i := range newData {
+		newData[i] = make([]Pixel, w)
+		copy(newData[i], ppm.data[y0+i][x0:x0+w])
+	}
+
+	ppm.data = newData
+	ppm.width, ppm.height = w, h
+	return nil
+}
+
+// MirrorExtend returns a new PPM image extended with mirrored copies of itself: a horizontal mirror on the right
+// and/or a vertical mirror at the bottom. The result tiles seamlessly.
+func (ppm *PPM) MirrorExtend(right, bottom bool) *PPM {
+	w, h := ppm.width, ppm.height
+	if right {
+		w *= 2
+	}
+	if bottom {
+		h *= 2
+	}
+
+	extended := newPPM(w, h, ppm.magicNumber, ppm.max)
+	for i := 0; i < h; i++ {
+		sy := i
+		if i >= ppm.height {
+			sy = 2*ppm.height - 1 - i
+		}
+		for j := 0; j < w; j++ {
+			sx := j
+			if j >= ppm.width {
+				sx = 2*ppm.width - 1 - j
+			}
+			extended.data[i][j] = ppm.data[sy][sx]
+		}
+	}
+	return extended
+}
+
+// Checksum returns a CRC32 of the dimensions and pixels of the PPM image, in the layout returned by Bytes.
+func (ppm *PPM) Checksum() uint32 {
+	return checksum(ppm.width, ppm.height, ppm.Bytes())
+}
+
+// ApplyLUT remaps each channel of the PPM image through its own 256-entry lookup table.
+func (ppm *PPM) ApplyLUT(r, g, b [256]uint8) {
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			p := &ppm.data[i][j]
+			p.R, p.G, p.B = r[p.R], g[p.G], b[p.B]
+		}
+	}
+}
+
+// DiffHeatmap returns a false-color image of the differences between a and b. Each pixel is colored by the largest
+// absolute difference of its channels, relative to the max value: from blue for identical pixels, through green,
+// to red for the largest possible difference. Both images must have the same dimensions.
+func DiffHeatmap(a, b *PPM) (*PPM, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("cannot compare a nil PPM")
+	}
+	if a.width != b.width || a.height != b.height {
+		return nil, fmt.Errorf("size mismatch: %dx%d and %dx%d", a.width, a.height, b.width, b.height)
+	}
+
+	maxValue := float64(max(a.max, b.max, 1))
+	heatmap := newPPM(a.width, a.height, "P3", 255)
+	for i := 0; i < a.height; i++ {
+		for j := 0; j < a.width; j++ {
+			diff := 0.0
+			for c := 0; c < 3; c++ {
+				diff = math.Max(diff, math.Abs(float64(*a.data[i][j].channel(c))-float64(*b.data[i][j].channel(c))))
+			}
+			t := math.Min(diff/maxValue, 1)
+
+			// Hue goes from 240° (blue) down to 0° (red)
+			r, g, bl := hsvToRGB(240*(1-t), 1, 1)
+			heatmap.data[i][j] = Pixel{clampSample(r*255, 255), clampSample(g*255, 255), clampSample(bl*255, 255)}
+		}
+	}
+	return heatmap, nil
+}
+
+// CropCircle returns a square image of side 2*radius+1 centered on center, keeping the pixels inside the circle
+// of the given radius and filling the corners with background. Parts of the circle outside the image are also
+// filled with background.
+func (ppm *PPM) CropCircle(center Point, radius int, background Pixel) *PPM {
+	radius = max(radius, 0)
+	size := 2*radius + 1
+	crop := newPPM(size, size, ppm.magicNumber, ppm.max)
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			p := Point{center.X - radius + j, center.Y - radius + i}
+			if CircleContains(center, radius, p) && p.X >= 0 && p.X < ppm.width && p.Y >= 0 && p.Y < ppm.height {
+				crop.data[i][j] = ppm.data[p.Y][p.X]
+			} else {
+				crop.data[i][j] = background
+			}
+		}
+	}
+	return crop
+}
+
+// RotateRegion rotates the content of the rectangle r of the PPM image in place by quarterTurns quarter turns
+// clockwise (counter-clockwise when negative), leaving the pixels outside r untouched.
+// The rectangle must lie inside the image, and must be square unless the rotation is a multiple of 180°.
+func (ppm *PPM) RotateRegion(r Rect, quarterTurns int) error {
+	if r.Width < 0 || r.Height < 0 || r.X < 0 || r.Y < 0 || r.X+r.Width > ppm.width || r.Y+r.Height > ppm.height {
+		return fmt.Errorf("region %+v outside of the %dx%d image", r, ppm.width, ppm.height)
+	}
+	turns := (quarterTurns%4 + 4) % 4
+	if turns%2 == 1 && r.Width != r.Height {
+		return fmt.Errorf("cannot rotate a non-square %dx%d region by a quarter turn", r.Width, r.Height)
+	}
+
+	region := make([][]Pixel, r.Height)
+	for i := range region {
+		region[i] = append([]Pixel(nil), ppm.data[r.Y+i][r.X:r.X+r.Width]...)
+	}
+	n := r.Width - 1
+	for i := 0; i < r.Height; i++ {
+		for j := 0; j < r.Width; j++ {
+			// Find the source pixel that lands on (j, i) after the rotation
+			si, sj := i, j
+			switch turns {
+			case 1:
+				si, sj = n-j, i
+			case 2:
+				si, sj = r.Height-1-i, n-j
+			case 3:
+				si, sj = j, n-i
+			}
+			ppm.data[r.Y+i][r.X+j] = region[si][sj]
+		}
+	}
+	return nil
+}
+
+// SimulateJPEG encodes the PPM image as JPEG in memory at the given quality (1 to 100) and decodes it back in place,
+// introducing the block and ringing artifacts of lossy compression. Samples are clamped to the max value.
+func (ppm *PPM) SimulateJPEG(quality int) error {
+	if quality < 1 || quality > 100 {
+		return fmt.Errorf("invalid JPEG quality: %d", quality)
+	}
+	if ppm.width <= 0 || ppm.height <= 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, ppm.ToImage(), &jpeg.Options{Quality: quality}); err != nil {
+		return fmt.Errorf("error encoding JPEG: %v", err)
+	}
+	img, err := jpeg.Decode(&buf)
+	if err != nil {
+		return fmt.Errorf("error decoding JPEG: %v", err)
+	}
+
+	bounds := img.Bounds()
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			c := color.RGBAModel.Convert(img.At(bounds.Min.X+j, bounds.Min.Y+i)).(color.RGBA)
+			ppm.data[i][j] = Pixel{clampSample(float64(c.R), ppm.max), clampSample(float64(c.G), ppm.max), clampSample(float64(c.B), ppm.max)}
+		}
+	}
+	return nil
+}
+
+// Orient applies the flips and rotations that bring a PPM image stored with the given EXIF orientation (1 to 8)
+// upright. Orientation 1 leaves the image unchanged.
+func (ppm *PPM) Orient(orientation int) error {
+	switch orientation {
+	case 1:
+	case 2:
+		ppm.Flip()
+	case 3:
+		ppm.Flip()
+		ppm.Flop()
+	case 4:
+		ppm.Flop()
+	case 5:
+		// Transpose
+		ppm.Rotate90CW()
+		ppm.Flip()
+	case 6:
+		ppm.Rotate90CW()
+	case 7:
+		// Transverse
+		ppm.Rotate90CW()
+		ppm.Flop()
+	case 8:
+		ppm.Rotate90CW()
+		ppm.Flip()
+		ppm.Flop()
+	default:
+		return fmt.Errorf("invalid EXIF orientation: %d", orientation)
+	}
+	return nil
+}
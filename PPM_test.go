@@ -0,0 +1,1437 @@
+package Netpbm
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestPPMValidateJagged(t *testing.T) {
+	ppm := &PPM{data: [][]Pixel{{{1, 2, 3}}, {}}, width: 1, height: 2, magicNumber: "P3", max: 255}
+	err := ppm.Validate()
+	if err == nil {
+		t.Fatal("Validate succeeded on a jagged grid")
+	}
+	if want := "invalid data: expected 1 pixels at row 1, got 0"; err.Error() != want {
+		t.Errorf("Validate() = %q, want %q", err, want)
+	}
+
+	ppm.data[1] = []Pixel{{4, 5, 6}}
+	ppm.max = 5
+	if err := ppm.Validate(); err == nil || err.Error() != "invalid pixel value (4 5 6) at row 1, column 0: exceeds max value 5" {
+		t.Errorf("Validate() with a sample above max = %v", err)
+	}
+}
+
+// patternPPM returns a width×height PPM with a different pattern in each channel.
+func patternPPM(width, height int) *PPM {
+	ppm := newPPM(width, height, "P3", 255)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			ppm.data[i][j] = Pixel{uint8(i * j), uint8(i + 3*j), uint8(j * j)}
+		}
+	}
+	return ppm
+}
+
+func TestPPMGaussianBlurParallelMatchesGaussianBlur(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	want := patternPPM(67, 41)
+	got := want.clone()
+	want.GaussianBlur(2.5)
+	got.GaussianBlurParallel(2.5)
+	if !reflect.DeepEqual(got.data, want.data) {
+		t.Error("GaussianBlurParallel differs from GaussianBlur")
+	}
+}
+
+func BenchmarkPPMGaussianBlur(b *testing.B) {
+	ppm := patternPPM(512, 512)
+	for i := 0; i < b.N; i++ {
+		ppm.GaussianBlur(2)
+	}
+}
+
+func BenchmarkPPMGaussianBlurParallel(b *testing.B) {
+	ppm := patternPPM(512, 512)
+	for i := 0; i < b.N; i++ {
+		ppm.GaussianBlurParallel(2)
+	}
+}
+
+func TestReadPPMInvalidDimensions(t *testing.T) {
+	for _, content := range []string{"P3\n-1 2\n255\n", "P3\n0 0\n255\n", "P3\n2\n255\n"} {
+		filename := filepath.Join(t.TempDir(), "image.ppm")
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadPPM(filename); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("ReadPPM(%q) error = %v, want %v", content, err, ErrInvalidHeader)
+		}
+	}
+}
+
+func TestReadPPMWhitespaceDimensions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "image.ppm")
+	if err := os.WriteFile(filename, []byte("P3\n2\t\n# comment\n1\n255\n1 2 3 4 5 6\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ppm, err := ReadPPM(filename)
+	if err != nil {
+		t.Fatalf("ReadPPM: %v", err)
+	}
+	if want := [][]Pixel{{{1, 2, 3}, {4, 5, 6}}}; !reflect.DeepEqual(ppm.data, want) {
+		t.Errorf("data = %v, want %v", ppm.data, want)
+	}
+}
+
+func TestGammaCorrectResizeCheckerboard(t *testing.T) {
+	ppm := newPPM(8, 8, "P3", 255)
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if (i+j)%2 == 0 {
+				ppm.data[i][j] = Pixel{255, 255, 255}
+			}
+		}
+	}
+	ppm.GammaCorrectResize(1, 1, 2.2)
+
+	// Half white in linear light is 0.5^(1/2.2)*255 ≈ 186, while averaging the encoded samples gives 128
+	if got := ppm.data[0][0]; got != (Pixel{186, 186, 186}) {
+		t.Errorf("resized pixel = %v, want {186 186 186}", got)
+	}
+}
+
+func TestGammaCorrectResizeEmpty(t *testing.T) {
+	ppm := newPPM(0, 0, "P3", 255)
+	ppm.GammaCorrectResize(4, 4, 2.2)
+	if w, h := ppm.Size(); w != 0 || h != 0 {
+		t.Errorf("Size() = %d, %d, want 0, 0", w, h)
+	}
+}
+
+// headerOnlyReader serves header and fails any read past it, to check that nothing reads the pixel data.
+type headerOnlyReader struct {
+	header []byte
+	pos    int
+}
+
+func (r *headerOnlyReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.header) {
+		return 0, errors.New("pixel data was read")
+	}
+	n := copy(p, r.header[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func TestDecodeConfigPPMReadsOnlyHeader(t *testing.T) {
+	r := &headerOnlyReader{header: []byte("P3\n# comment\n4 3\n255\n")}
+	config, err := DecodeConfigPPM(r)
+	if err != nil {
+		t.Fatalf("DecodeConfigPPM: %v", err)
+	}
+	if config.Width != 4 || config.Height != 3 || config.ColorModel != color.RGBAModel {
+		t.Errorf("config = %+v, want 4x3 RGBA", config)
+	}
+}
+
+func TestImageDecodeConfigPPM(t *testing.T) {
+	data := []byte("P3\n2 1\n255\n255 0 0 0 0 255\n")
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("image.DecodeConfig: %v", err)
+	}
+	if format != "ppm" || config.Width != 2 || config.Height != 1 {
+		t.Errorf("image.DecodeConfig = %+v, %q, want 2x1 ppm", config, format)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("image.Decode: %v", err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); format != "ppm" || r != 0xffff {
+		t.Errorf("image.Decode = %v, %q, want a red first pixel", img.At(0, 0), format)
+	}
+}
+
+var (
+	black = Pixel{0, 0, 0}
+	white = Pixel{255, 255, 255}
+	red   = Pixel{255, 0, 0}
+)
+
+// countColor returns the number of pixels of the PPM image equal to color.
+func countColor(ppm *PPM, color Pixel) int {
+	return ppm.CountIf(func(p Pixel) bool { return p == color })
+}
+
+func TestDrawHorizontalLineFullRow(t *testing.T) {
+	ppm := newPPM(10, 5, "P3", 255)
+	ppm.DrawHorizontalLine(2, -5, 20, red)
+
+	for x := 0; x < 10; x++ {
+		if ppm.At(x, 2) != red {
+			t.Errorf("pixel (%d, 2) not set", x)
+		}
+	}
+	if n := countColor(ppm, red); n != 10 {
+		t.Errorf("%d pixels set, want 10", n)
+	}
+
+	// Reversed endpoints and rows outside the image
+	ppm.DrawHorizontalLine(0, 3, 1, white)
+	ppm.DrawHorizontalLine(-1, 0, 9, white)
+	ppm.DrawHorizontalLine(5, 0, 9, white)
+	if n := countColor(ppm, white); n != 3 {
+		t.Errorf("%d pixels set by the reversed line, want 3", n)
+	}
+}
+
+func TestDrawVerticalLineFullColumn(t *testing.T) {
+	ppm := newPPM(5, 10, "P3", 255)
+	ppm.DrawVerticalLine(3, 20, -5, red)
+
+	for y := 0; y < 10; y++ {
+		if ppm.At(3, y) != red {
+			t.Errorf("pixel (3, %d) not set", y)
+		}
+	}
+	if n := countColor(ppm, red); n != 10 {
+		t.Errorf("%d pixels set, want 10", n)
+	}
+
+	ppm.DrawVerticalLine(5, 0, 9, white)
+	if n := countColor(ppm, white); n != 0 {
+		t.Errorf("%d pixels set by a column outside the image, want 0", n)
+	}
+}
+
+func TestFillTriangleOrientations(t *testing.T) {
+	triangles := [][3]Point{
+		{{2, 2}, {37, 5}, {10, 30}},
+		{{35, 2}, {38, 38}, {2, 20}},
+		{{20, 1}, {38, 38}, {1, 38}},
+		{{1, 1}, {38, 1}, {20, 38}},
+		{{5, 35}, {30, 3}, {38, 25}},
+	}
+	for _, tri := range triangles {
+		a, b, c := tri[0], tri[1], tri[2]
+		area := math.Abs(float64((b.X-a.X)*(c.Y-a.Y)-(c.X-a.X)*(b.Y-a.Y))) / 2
+		perimeter := math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y)) +
+			math.Hypot(float64(c.X-b.X), float64(c.Y-b.Y)) +
+			math.Hypot(float64(a.X-c.X), float64(a.Y-c.Y))
+
+		// Every vertex order, clockwise and counter-clockwise, must fill the same pixels
+		want := -1
+		for _, order := range [][3]Point{{a, b, c}, {a, c, b}, {b, a, c}, {b, c, a}, {c, a, b}, {c, b, a}} {
+			ppm := newPPM(40, 40, "P3", 255)
+			ppm.FillTriangle(order[0], order[1], order[2], red)
+			n := countColor(ppm, red)
+			if want == -1 {
+				want = n
+			} else if n != want {
+				t.Errorf("triangle %v: %d pixels filled for order %v, want %d", tri, n, order, want)
+			}
+		}
+
+		// Pixels on the edges are included, so allow up to the perimeter in excess
+		if float64(want) < area || float64(want) > area+perimeter {
+			t.Errorf("triangle %v: %d pixels filled, want between %.1f and %.1f", tri, want, area, area+perimeter)
+		}
+	}
+}
+
+func TestFillTriangleDegenerate(t *testing.T) {
+	ppm := newPPM(10, 10, "P3", 255)
+	ppm.FillTriangle(Point{1, 1}, Point{5, 5}, Point{8, 8}, red)
+	if n := countColor(ppm, red); n != 8 {
+		t.Errorf("collinear triangle filled %d pixels, want the 8 pixels of its line", n)
+	}
+}
+
+func TestInvertChannelRed(t *testing.T) {
+	ppm := patternPPM(6, 4)
+	original := ppm.clone()
+	if err := ppm.InvertChannel(0); err != nil {
+		t.Fatalf("InvertChannel: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 6; j++ {
+			got, old := ppm.data[i][j], original.data[i][j]
+			if got != (Pixel{255 - old.R, old.G, old.B}) {
+				t.Errorf("pixel (%d, %d) = %v, want only red of %v inverted", j, i, got, old)
+			}
+		}
+	}
+
+	for _, channel := range []int{-1, 3} {
+		if err := ppm.InvertChannel(channel); err == nil {
+			t.Errorf("InvertChannel(%d) succeeded", channel)
+		}
+	}
+}
+
+func TestSwapChannelsBGR(t *testing.T) {
+	ppm := patternPPM(6, 4)
+	original := ppm.clone()
+	if err := ppm.SwapChannels("BGR"); err != nil {
+		t.Fatalf("SwapChannels: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 6; j++ {
+			got, old := ppm.data[i][j], original.data[i][j]
+			if got != (Pixel{old.B, old.G, old.R}) {
+				t.Errorf("pixel (%d, %d) = %v, want red and blue of %v exchanged", j, i, got, old)
+			}
+		}
+	}
+
+	for _, order := range []string{"RG", "RGBA", "RRB", "RGX", "rgb"} {
+		if err := ppm.SwapChannels(order); err == nil {
+			t.Errorf("SwapChannels(%q) succeeded", order)
+		}
+	}
+}
+
+func TestWhiteBalanceDoubleRed(t *testing.T) {
+	ppm := newPPM(2, 1, "P3", 255)
+	ppm.data[0] = []Pixel{{100, 50, 20}, {200, 50, 20}}
+	ppm.WhiteBalance(2, 1, 1)
+
+	// 100 scales to 200 and 200 saturates at the max value
+	want := []Pixel{{200, 50, 20}, {255, 50, 20}}
+	if !reflect.DeepEqual(ppm.data[0], want) {
+		t.Errorf("pixels = %v, want %v", ppm.data[0], want)
+	}
+}
+
+func TestAdjustSaturation(t *testing.T) {
+	ppm := patternPPM(6, 4)
+	desaturated := ppm.clone()
+	desaturated.Desaturate()
+	ppm.AdjustSaturation(0)
+	if !reflect.DeepEqual(ppm.data, desaturated.data) {
+		t.Error("AdjustSaturation(0) differs from Desaturate")
+	}
+	for _, p := range ppm.data[3] {
+		if p.R != p.G || p.G != p.B {
+			t.Errorf("AdjustSaturation(0) left color in %v", p)
+		}
+	}
+
+	// Luma of (100, 150, 200) is 140.75, and every channel moves twice as far from it
+	boosted := newPPM(1, 1, "P3", 255)
+	boosted.data[0][0] = Pixel{100, 150, 200}
+	boosted.AdjustSaturation(2)
+	if got, want := boosted.data[0][0], (Pixel{59, 159, 255}); got != want {
+		t.Errorf("AdjustSaturation(2) = %v, want %v", got, want)
+	}
+}
+
+// closePixel reports whether every channel of a and b differs by at most tolerance.
+func closePixel(a, b Pixel, tolerance int) bool {
+	for c := 0; c < 3; c++ {
+		d := int(*a.channel(c)) - int(*b.channel(c))
+		if d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRotateHue(t *testing.T) {
+	tests := []struct {
+		degrees float64
+		want    Pixel
+	}{
+		{120, Pixel{0, 255, 0}},
+		{240, Pixel{0, 0, 255}},
+		{480, Pixel{0, 255, 0}},
+		{-120, Pixel{0, 0, 255}},
+		{360, red},
+	}
+	for _, tt := range tests {
+		ppm := newPPM(1, 1, "P3", 255)
+		ppm.data[0][0] = red
+		ppm.RotateHue(tt.degrees)
+		if got := ppm.data[0][0]; !closePixel(got, tt.want, 1) {
+			t.Errorf("RotateHue(%v) of red = %v, want %v", tt.degrees, got, tt.want)
+		}
+	}
+}
+
+func TestPPMUnsharpMaskStepEdge(t *testing.T) {
+	ppm := stepPGM(20, 5, 50, 200).ToPPM()
+	ppm.UnsharpMask(1, 1)
+
+	if p := ppm.data[2][9]; p.R >= 50 || p.G >= 50 || p.B >= 50 {
+		t.Errorf("dark side of the edge = %v, want undershoot below 50", p)
+	}
+	if p := ppm.data[2][10]; p.R <= 200 || p.G <= 200 || p.B <= 200 {
+		t.Errorf("bright side of the edge = %v, want overshoot above 200", p)
+	}
+}
+
+func TestDrawLineAlphaAverages(t *testing.T) {
+	background := Pixel{100, 100, 100}
+	lines := [][2]Point{{{0, 0}, {19, 7}}, {{3, 18}, {15, 2}}, {{-10, 5}, {30, 12}}, {{5, 5}, {5, 5}}}
+	for _, line := range lines {
+		opaque := newPPM(20, 20, "P3", 255)
+		opaque.Clear(background)
+		opaque.DrawLine(line[0], line[1], Pixel{200, 0, 50})
+
+		blended := newPPM(20, 20, "P3", 255)
+		blended.Clear(background)
+		blended.DrawLineAlpha(line[0], line[1], Pixel{200, 0, 50}, 0.5)
+
+		for i := 0; i < 20; i++ {
+			for j := 0; j < 20; j++ {
+				want := background
+				if opaque.data[i][j] != background {
+					want = Pixel{150, 50, 75}
+				}
+				if got := blended.data[i][j]; got != want {
+					t.Errorf("line %v: pixel (%d, %d) = %v, want %v", line, j, i, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPPMCountIfBrighter(t *testing.T) {
+	ppm := patternPPM(17, 11)
+	bright := func(p Pixel) bool { return int(p.R)+int(p.G)+int(p.B) > 300 }
+	want := 0
+	for _, row := range ppm.data {
+		for _, p := range row {
+			if bright(p) {
+				want++
+			}
+		}
+	}
+	if got := ppm.CountIf(bright); got != want || want == 0 {
+		t.Errorf("CountIf = %d, want %d", got, want)
+	}
+}
+
+func TestPPMStats(t *testing.T) {
+	ppm := newPPM(2, 2, "P3", 255)
+	ppm.data = [][]Pixel{{{10, 0, 5}, {30, 0, 5}}, {{10, 0, 5}, {30, 0, 5}}}
+	lo, hi, mean, stddev := ppm.Stats()
+	if lo != (Pixel{10, 0, 5}) || hi != (Pixel{30, 0, 5}) {
+		t.Errorf("min, max = %v, %v, want {10 0 5}, {30 0 5}", lo, hi)
+	}
+	if mean != [3]float64{20, 0, 5} || stddev != [3]float64{10, 0, 0} {
+		t.Errorf("mean, stddev = %v, %v, want [20 0 5], [10 0 0]", mean, stddev)
+	}
+}
+
+func TestSetManyClips(t *testing.T) {
+	ppm := newPPM(5, 4, "P3", 255)
+	points := []Point{{0, 0}, {4, 3}, {2, 1}, {-1, 0}, {5, 0}, {0, 4}, {1, -3}}
+	ppm.SetMany(points, red)
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 5; j++ {
+			want := black
+			if (j == 0 && i == 0) || (j == 4 && i == 3) || (j == 2 && i == 1) {
+				want = red
+			}
+			if got := ppm.data[i][j]; got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", j, i, got, want)
+			}
+		}
+	}
+}
+
+func TestSetRow(t *testing.T) {
+	ppm := newPPM(3, 2, "P3", 255)
+	row := []Pixel{red, white, red}
+	if err := ppm.SetRow(1, row); err != nil {
+		t.Fatalf("SetRow: %v", err)
+	}
+	if !reflect.DeepEqual(ppm.data[1], row) || countColor(ppm, black) != 3 {
+		t.Errorf("data = %v, want row 1 set to %v", ppm.data, row)
+	}
+
+	row[0] = white
+	if ppm.data[1][0] != red {
+		t.Error("SetRow kept a reference to the caller's slice")
+	}
+	if err := ppm.SetRow(2, row); err == nil {
+		t.Error("SetRow(2) succeeded on a 2-row image")
+	}
+	if err := ppm.SetRow(0, row[:2]); err == nil {
+		t.Error("SetRow with a short row succeeded")
+	}
+}
+
+func TestTile(t *testing.T) {
+	tile := newPPM(2, 2, "P3", 255)
+	tile.data = [][]Pixel{{{1, 0, 0}, {2, 0, 0}}, {{3, 0, 0}, {4, 0, 0}}}
+	tiled := tile.Tile(2, 2)
+
+	if w, h := tiled.Size(); w != 4 || h != 4 {
+		t.Fatalf("Size() = %d, %d, want 4, 4", w, h)
+	}
+	for _, offset := range []Point{{0, 0}, {2, 0}, {0, 2}, {2, 2}} {
+		for i := 0; i < 2; i++ {
+			for j := 0; j < 2; j++ {
+				if got := tiled.At(offset.X+j, offset.Y+i); got != tile.data[i][j] {
+					t.Errorf("tile at %v: pixel (%d, %d) = %v, want %v", offset, j, i, got, tile.data[i][j])
+				}
+			}
+		}
+	}
+
+	if tile.Tile(0, 2) != nil || tile.Tile(2, -1) != nil {
+		t.Error("Tile accepted a non-positive count")
+	}
+}
+
+func TestMontagePositions(t *testing.T) {
+	colors := []Pixel{{10, 0, 0}, {20, 0, 0}, {30, 0, 0}, {40, 0, 0}}
+	sizes := [][2]int{{3, 2}, {2, 3}, {3, 3}, {1, 1}}
+	images := make([]*PPM, 4)
+	for n := range images {
+		images[n] = newPPM(sizes[n][0], sizes[n][1], "P3", 255)
+		images[n].Clear(colors[n])
+	}
+
+	montage, err := Montage(images, 2, 1, white)
+	if err != nil {
+		t.Fatalf("Montage: %v", err)
+	}
+	// Cells are 3x3, so two columns and two rows with a 1 pixel gap give 7x7
+	if w, h := montage.Size(); w != 7 || h != 7 {
+		t.Fatalf("Size() = %d, %d, want 7, 7", w, h)
+	}
+	for n, img := range images {
+		x0, y0 := (n%2)*4, (n/2)*4
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				want := white
+				if j < img.width && i < img.height {
+					want = colors[n]
+				}
+				if got := montage.At(x0+j, y0+i); got != want {
+					t.Errorf("image %d: pixel (%d, %d) = %v, want %v", n, x0+j, y0+i, got, want)
+				}
+			}
+		}
+	}
+	for k := 0; k < 7; k++ {
+		if montage.At(3, k) != white || montage.At(k, 3) != white {
+			t.Errorf("gap pixel at row or column %d is not the background", k)
+		}
+	}
+
+	if _, err := Montage(nil, 2, 0, white); err == nil {
+		t.Error("Montage of no images succeeded")
+	}
+}
+
+func TestPPMIsUniform(t *testing.T) {
+	solid := newPPM(4, 3, "P3", 255)
+	solid.Clear(red)
+	if ok, color := solid.IsUniform(); !ok || color != red {
+		t.Errorf("IsUniform() of a solid image = %v, %v, want true, %v", ok, color, red)
+	}
+	if ok, _ := patternPPM(4, 3).IsUniform(); ok {
+		t.Error("IsUniform() of a gradient = true")
+	}
+}
+
+func TestScaleToFit(t *testing.T) {
+	ppm := patternPPM(200, 100)
+	if w, h := ppm.ScaleToFit(50, 50); w != 50 || h != 25 {
+		t.Errorf("ScaleToFit(50, 50) = %d, %d, want 50, 25", w, h)
+	}
+	if w, h := ppm.Size(); w != 50 || h != 25 || len(ppm.data) != 25 || len(ppm.data[0]) != 50 {
+		t.Errorf("Size() after ScaleToFit = %d, %d, want 50, 25", w, h)
+	}
+
+	tall := patternPPM(30, 90)
+	if w, h := tall.ScaleToFit(60, 60); w != 20 || h != 60 {
+		t.Errorf("ScaleToFit(60, 60) of 30x90 = %d, %d, want 20, 60", w, h)
+	}
+}
+
+func TestRectContains(t *testing.T) {
+	r := Rect{2, 3, 4, 5}
+	tests := []struct {
+		p    Point
+		want bool
+	}{
+		{Point{3, 4}, true},
+		{Point{2, 3}, true},  // top-left corner
+		{Point{5, 7}, true},  // last pixel inside
+		{Point{6, 4}, false}, // right edge
+		{Point{3, 8}, false}, // bottom edge
+		{Point{1, 4}, false},
+	}
+	for _, tt := range tests {
+		if got := r.Contains(tt.p); got != tt.want {
+			t.Errorf("%+v.Contains(%v) = %v, want %v", r, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestCircleContains(t *testing.T) {
+	center := Point{10, 10}
+	tests := []struct {
+		p    Point
+		want bool
+	}{
+		{Point{10, 10}, true},
+		{Point{12, 11}, true},
+		{Point{15, 10}, true}, // on the boundary
+		{Point{13, 14}, true}, // on the boundary
+		{Point{14, 14}, false},
+		{Point{16, 10}, false},
+	}
+	for _, tt := range tests {
+		if got := CircleContains(center, 5, tt.p); got != tt.want {
+			t.Errorf("CircleContains(%v, 5, %v) = %v, want %v", center, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestTriangleContains(t *testing.T) {
+	a, b, c := Point{0, 0}, Point{10, 0}, Point{0, 10}
+	tests := []struct {
+		p    Point
+		want bool
+	}{
+		{Point{2, 2}, true},
+		{Point{5, 0}, true},  // on an edge
+		{Point{5, 5}, true},  // on the hypotenuse
+		{Point{0, 10}, true}, // a vertex
+		{Point{6, 6}, false},
+		{Point{-1, 2}, false},
+	}
+	for _, tt := range tests {
+		for _, order := range [][3]Point{{a, b, c}, {c, b, a}} {
+			if got := TriangleContains(order[0], order[1], order[2], tt.p); got != tt.want {
+				t.Errorf("TriangleContains(%v, %v) = %v, want %v", order, tt.p, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestAverage(t *testing.T) {
+	images := make([]*PPM, 3)
+	for n := range images {
+		images[n] = newPPM(3, 2, "P3", 255)
+		images[n].Clear(Pixel{100, 50, 10})
+	}
+	// A noisy pixel in one frame, and values whose mean needs rounding
+	images[1].data[1][2] = Pixel{250, 50, 11}
+	images[2].data[1][2] = Pixel{100, 51, 11}
+
+	avg, err := Average(images)
+	if err != nil {
+		t.Fatalf("Average: %v", err)
+	}
+	// (100+250+100)/3 = 150, (50+50+51)/3 = 50.33 and (10+11+11)/3 = 10.67
+	if got, want := avg.data[1][2], (Pixel{150, 50, 11}); got != want {
+		t.Errorf("noisy pixel average = %v, want %v", got, want)
+	}
+	if n := countColor(avg, Pixel{100, 50, 10}); n != 5 {
+		t.Errorf("%d unchanged pixels, want 5", n)
+	}
+
+	if _, err := Average([]*PPM{images[0], newPPM(2, 3, "P3", 255)}); err == nil {
+		t.Error("Average of different sizes succeeded")
+	}
+}
+
+func TestFillBackgroundSentinel(t *testing.T) {
+	sentinel := Pixel{1, 2, 3}
+	ppm := newPPM(10, 10, "P3", 255)
+	ppm.Clear(sentinel)
+	ppm.DrawFilledRectangle(Point{2, 2}, 4, 3, red)
+	ppm.FillBackground(sentinel, white)
+
+	if n := countColor(ppm, red); n != 12 {
+		t.Errorf("%d shape pixels, want 12 untouched", n)
+	}
+	if n := countColor(ppm, white); n != 88 {
+		t.Errorf("%d background pixels, want 88 filled", n)
+	}
+	if n := countColor(ppm, sentinel); n != 0 {
+		t.Errorf("%d sentinel pixels left", n)
+	}
+}
+
+func TestRotateCrop45(t *testing.T) {
+	ppm := newPPM(9, 9, "P3", 255)
+	ppm.DrawLine(Point{0, 4}, Point{8, 4}, red)
+	ppm.RotateCrop(45, white)
+
+	if ppm.width != 9 || ppm.height != 9 || len(ppm.data) != 9 || len(ppm.data[0]) != 9 {
+		t.Fatalf("size = %dx%d, want 9x9", ppm.width, ppm.height)
+	}
+	// The horizontal line through the center now runs along the main diagonal
+	for _, p := range []Point{{4, 4}, {3, 3}, {5, 5}, {2, 2}, {6, 6}} {
+		if got := ppm.data[p.Y][p.X]; got != red {
+			t.Errorf("pixel %v = %v, want %v on the rotated line", p, got, red)
+		}
+	}
+	for _, p := range []Point{{8, 4}, {0, 4}, {5, 4}} {
+		if got := ppm.data[p.Y][p.X]; got == red {
+			t.Errorf("pixel %v is still on the unrotated line", p)
+		}
+	}
+	if got := ppm.data[0][8]; got != white {
+		t.Errorf("corner pixel = %v, want background %v", got, white)
+	}
+}
+
+func TestAutoLevels(t *testing.T) {
+	ppm := newPPM(20, 10, "P3", 255)
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			v := uint8(100 + (i*ppm.width+j)%50)
+			ppm.data[i][j] = Pixel{v, v / 2, 255 - v}
+		}
+	}
+	const clipPercent = 5
+	ppm.AutoLevels(clipPercent)
+
+	total := ppm.width * ppm.height
+	clip := total * clipPercent / 100
+	for c := 0; c < 3; c++ {
+		low, high, atLow, atHigh := 255, 0, 0, 0
+		for i := 0; i < ppm.height; i++ {
+			for j := 0; j < ppm.width; j++ {
+				v := int(*ppm.data[i][j].channel(c))
+				low, high = min(low, v), max(high, v)
+				if v == 0 {
+					atLow++
+				}
+				if v == 255 {
+					atHigh++
+				}
+			}
+		}
+		if low != 0 || high != 255 {
+			t.Errorf("channel %d spans [%d, %d], want [0, 255]", c, low, high)
+		}
+		// Each end clips about clipPercent of the pixels
+		if atLow < clip/2 || atLow > 2*clip {
+			t.Errorf("channel %d clips %d pixels to black, want about %d", c, atLow, clip)
+		}
+		if atHigh < clip/2 || atHigh > 2*clip {
+			t.Errorf("channel %d clips %d pixels to white, want about %d", c, atHigh, clip)
+		}
+	}
+}
+
+func TestPPMBytes(t *testing.T) {
+	ppm := patternPPM(5, 4)
+	ppm.data[0][0] = Pixel{10, 20, 30}
+	data := ppm.Bytes()
+
+	if len(data) != 5*4*3 {
+		t.Fatalf("len(Bytes()) = %d, want %d", len(data), 5*4*3)
+	}
+	if data[0] != 10 || data[1] != 20 || data[2] != 30 {
+		t.Errorf("first pixel bytes = %v, want [10 20 30]", data[:3])
+	}
+	// Pixel (x, y) starts at 3*(y*width+x)
+	p := ppm.data[2][3]
+	if k := 3 * (2*5 + 3); data[k] != p.R || data[k+1] != p.G || data[k+2] != p.B {
+		t.Errorf("pixel (3, 2) bytes = %v, want %v", data[k:k+3], p)
+	}
+}
+
+func TestPPMFromBytes(t *testing.T) {
+	ppm := patternPPM(6, 4)
+	got, err := PPMFromBytes(ppm.Bytes(), ppm.width, ppm.height, ppm.max)
+	if err != nil {
+		t.Fatalf("PPMFromBytes: %v", err)
+	}
+	if got.width != ppm.width || got.height != ppm.height || got.max != ppm.max {
+		t.Errorf("got %dx%d max %d, want %dx%d max %d", got.width, got.height, got.max, ppm.width, ppm.height, ppm.max)
+	}
+	if !reflect.DeepEqual(got.data, ppm.data) {
+		t.Errorf("round trip changed the pixels: got %v, want %v", got.data, ppm.data)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		max  uint
+	}{
+		{"short data", make([]byte, 5), 255},
+		{"zero max", make([]byte, 6), 0},
+		{"max above 255", make([]byte, 6), 256},
+		{"sample above max", []byte{0, 0, 0, 0, 16, 0}, 15},
+	}
+	for _, tt := range tests {
+		if _, err := PPMFromBytes(tt.data, 2, 1, tt.max); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestPPMRowColumn(t *testing.T) {
+	ppm := patternPPM(5, 3)
+
+	row := ppm.Row(1)
+	if len(row) != 5 {
+		t.Fatalf("len(Row(1)) = %d, want 5", len(row))
+	}
+	for x, p := range row {
+		if want := (Pixel{uint8(x), uint8(1 + 3*x), uint8(x * x)}); p != want {
+			t.Errorf("Row(1)[%d] = %v, want %v", x, p, want)
+		}
+	}
+	column := ppm.Column(2)
+	if len(column) != 3 {
+		t.Fatalf("len(Column(2)) = %d, want 3", len(column))
+	}
+	for y, p := range column {
+		if want := (Pixel{uint8(2 * y), uint8(y + 6), 4}); p != want {
+			t.Errorf("Column(2)[%d] = %v, want %v", y, p, want)
+		}
+	}
+
+	// The slices are copies
+	row[0] = red
+	if ppm.data[1][0] == red {
+		t.Error("modifying the row changed the image")
+	}
+
+	if ppm.Row(-1) != nil || ppm.Row(3) != nil || ppm.Column(-1) != nil || ppm.Column(5) != nil {
+		t.Error("out-of-range lines should return nil")
+	}
+}
+
+func TestDownsample2x(t *testing.T) {
+	ppm := patternPPM(6, 4)
+	half := ppm.Downsample2x()
+
+	if half.width != 3 || half.height != 2 {
+		t.Fatalf("size = %dx%d, want 3x2", half.width, half.height)
+	}
+	for i := 0; i < half.height; i++ {
+		for j := 0; j < half.width; j++ {
+			a, b, c, d := ppm.data[2*i][2*j], ppm.data[2*i][2*j+1], ppm.data[2*i+1][2*j], ppm.data[2*i+1][2*j+1]
+			want := Pixel{
+				uint8(math.Round(float64(int(a.R)+int(b.R)+int(c.R)+int(d.R)) / 4)),
+				uint8(math.Round(float64(int(a.G)+int(b.G)+int(c.G)+int(d.G)) / 4)),
+				uint8(math.Round(float64(int(a.B)+int(b.B)+int(c.B)+int(d.B)) / 4)),
+			}
+			if got := half.data[i][j]; got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", j, i, got, want)
+			}
+		}
+	}
+
+	// Odd dimensions round up
+	if odd := patternPPM(5, 3).Downsample2x(); odd.width != 3 || odd.height != 2 {
+		t.Errorf("odd size = %dx%d, want 3x2", odd.width, odd.height)
+	}
+}
+
+func TestGaussianPyramid(t *testing.T) {
+	pyramid := patternPPM(40, 24).GaussianPyramid(4)
+	if len(pyramid) != 4 {
+		t.Fatalf("len = %d, want 4", len(pyramid))
+	}
+	for i := 1; i < len(pyramid); i++ {
+		prev, cur := pyramid[i-1], pyramid[i]
+		if cur.width != (prev.width+1)/2 || cur.height != (prev.height+1)/2 {
+			t.Errorf("level %d is %dx%d, want half of %dx%d", i, cur.width, cur.height, prev.width, prev.height)
+		}
+	}
+
+	// Building stops once a dimension reaches 1
+	if n := len(patternPPM(8, 2).GaussianPyramid(10)); n != 2 {
+		t.Errorf("len = %d for an 8x2 image, want 2", n)
+	}
+	if patternPPM(4, 4).GaussianPyramid(0) != nil {
+		t.Error("zero levels should return nil")
+	}
+}
+
+func TestHueCycleFrames(t *testing.T) {
+	base := newPPM(4, 4, "P3", 255)
+	base.Clear(red)
+	frames := HueCycleFrames(base, 4)
+
+	if len(frames) != 4 {
+		t.Fatalf("len = %d, want 4", len(frames))
+	}
+	if frames[0] == base || !reflect.DeepEqual(frames[0].data, base.data) {
+		t.Error("frame 0 should be an exact copy of base")
+	}
+	for i, frame := range frames[1:] {
+		if reflect.DeepEqual(frame.data, base.data) {
+			t.Errorf("frame %d has the same hue as base", i+1)
+		}
+	}
+	// Rotating red by 180° gives cyan
+	if got := frames[2].data[0][0]; !closePixel(got, Pixel{0, 255, 255}, 1) {
+		t.Errorf("frame 2 = %v, want cyan", got)
+	}
+	if HueCycleFrames(base, 0) != nil {
+		t.Error("zero frames should return nil")
+	}
+}
+
+func TestPPMReset(t *testing.T) {
+	ppm := patternPPM(5, 3)
+	ppm.Reset()
+
+	if ppm.width != 5 || ppm.height != 3 || len(ppm.data) != 3 || len(ppm.data[0]) != 5 {
+		t.Fatalf("size changed to %dx%d", ppm.width, ppm.height)
+	}
+	if n := countColor(ppm, black); n != 15 {
+		t.Errorf("%d black pixels, want 15", n)
+	}
+}
+
+func TestNewRadialGradient(t *testing.T) {
+	inner, outer := Pixel{255, 200, 0}, Pixel{0, 40, 255}
+	ppm := NewRadialGradient(21, 15, Point{10, 7}, inner, outer)
+
+	if ppm.width != 21 || ppm.height != 15 {
+		t.Fatalf("size = %dx%d, want 21x15", ppm.width, ppm.height)
+	}
+	if got := ppm.data[7][10]; got != inner {
+		t.Errorf("center = %v, want %v", got, inner)
+	}
+	for _, p := range []Point{{0, 0}, {20, 0}, {0, 14}, {20, 14}} {
+		if got := ppm.data[p.Y][p.X]; !closePixel(got, outer, 2) {
+			t.Errorf("corner %v = %v, want close to %v", p, got, outer)
+		}
+	}
+}
+
+func TestBlendWithMultiply(t *testing.T) {
+	base := patternPPM(5, 4)
+	other := newPPM(5, 4, "P3", 255)
+	other.Clear(white)
+
+	ppm := base.clone()
+	if err := ppm.BlendWith(other, BlendMultiply, 1); err != nil {
+		t.Fatalf("BlendWith white: %v", err)
+	}
+	if !reflect.DeepEqual(ppm.data, base.data) {
+		t.Errorf("multiplying by white changed the image: got %v, want %v", ppm.data, base.data)
+	}
+
+	other.Clear(black)
+	if err := ppm.BlendWith(other, BlendMultiply, 1); err != nil {
+		t.Fatalf("BlendWith black: %v", err)
+	}
+	if n := countColor(ppm, black); n != 20 {
+		t.Errorf("%d black pixels after multiplying by black, want 20", n)
+	}
+
+	if err := ppm.BlendWith(newPPM(4, 4, "P3", 255), BlendMultiply, 1); err == nil {
+		t.Error("expected an error for mismatched sizes")
+	}
+}
+
+func TestRemoveVerticalSeams(t *testing.T) {
+	// A flat gray area on the left and high-contrast stripes two pixels wide on the right
+	ppm := newPPM(15, 8, "P3", 255)
+	for i := 0; i < ppm.height; i++ {
+		for j := 0; j < ppm.width; j++ {
+			ppm.data[i][j] = Pixel{128, 128, 128}
+			if j >= 8 && (j-8)/2%2 == 0 {
+				ppm.data[i][j] = white
+			} else if j >= 8 {
+				ppm.data[i][j] = black
+			}
+		}
+	}
+	want := make([][]Pixel, ppm.height)
+	for i := range want {
+		want[i] = append([]Pixel(nil), ppm.data[i][8:]...)
+	}
+
+	if err := ppm.RemoveVerticalSeams(3); err != nil {
+		t.Fatalf("RemoveVerticalSeams: %v", err)
+	}
+	if ppm.width != 12 {
+		t.Fatalf("width = %d, want 12", ppm.width)
+	}
+	for i := range ppm.data {
+		if len(ppm.data[i]) != 12 {
+			t.Fatalf("row %d has %d pixels, want 12", i, len(ppm.data[i]))
+		}
+		// The seams go through the flat area, so the stripes are kept intact
+		if got := ppm.data[i][5:]; !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("row %d ends with %v, want %v", i, got, want[i])
+		}
+	}
+
+	if err := ppm.RemoveVerticalSeams(12); err == nil {
+		t.Error("expected an error when removing every column")
+	}
+}
+
+func TestDrawRuler(t *testing.T) {
+	ppm := newPPM(25, 25, "P3", 255)
+	ppm.DrawRuler(red, 2)
+
+	// Row 1 crosses every top tick and no left tick, column 1 every left tick and no top tick
+	for x := 0; x < 25; x++ {
+		if got := ppm.data[1][x] == red; got != (x%2 == 0) {
+			t.Errorf("top tick at x=%d: got %v, want %v", x, got, x%2 == 0)
+		}
+	}
+	for y := 0; y < 25; y++ {
+		if got := ppm.data[y][1] == red; got != (y%2 == 0) {
+			t.Errorf("left tick at y=%d: got %v, want %v", y, got, y%2 == 0)
+		}
+	}
+
+	// Ticks at multiples of 10 marks are longer
+	tests := []struct {
+		x, length int
+	}{{4, rulerTickLength}, {20, rulerLongTickLength}}
+	for _, tt := range tests {
+		if ppm.data[tt.length-1][tt.x] != red || ppm.data[tt.length][tt.x] == red {
+			t.Errorf("tick at x=%d is not %d pixels long", tt.x, tt.length)
+		}
+		if ppm.data[tt.x][tt.length-1] != red || ppm.data[tt.x][tt.length] == red {
+			t.Errorf("tick at y=%d is not %d pixels long", tt.x, tt.length)
+		}
+	}
+}
+
+func TestDrawCircleAA(t *testing.T) {
+	ppm := newPPM(21, 21, "P3", 255)
+	ppm.DrawCircleAA(Point{10, 10}, 5, white)
+
+	// Pixels whose center lies on the boundary are half covered
+	for _, p := range []Point{{15, 10}, {5, 10}, {10, 15}, {10, 5}, {13, 14}} {
+		if got := ppm.data[p.Y][p.X]; got == black || got == white || !closePixel(got, Pixel{128, 128, 128}, 2) {
+			t.Errorf("boundary pixel %v = %v, want a partial intensity", p, got)
+		}
+	}
+	for _, p := range []Point{{10, 10}, {12, 12}, {14, 10}, {10, 6}} {
+		if got := ppm.data[p.Y][p.X]; got != white {
+			t.Errorf("inside pixel %v = %v, want %v", p, got, white)
+		}
+	}
+	for _, p := range []Point{{16, 10}, {10, 3}, {15, 15}, {0, 0}} {
+		if got := ppm.data[p.Y][p.X]; got != black {
+			t.Errorf("outside pixel %v = %v, want untouched", p, got)
+		}
+	}
+}
+
+func TestTriangleFarOutsideCanvas(t *testing.T) {
+	p1, p2, p3 := Point{2, 2}, Point{2, 17}, Point{1000000, 2}
+
+	filled := newPPM(20, 20, "P3", 255)
+	filled.DrawFilledTriangle(p1, p2, p3, red)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			want := TriangleContains(p1, p2, p3, Point{x, y})
+			if got := filled.data[y][x] == red; got != want {
+				t.Errorf("filled pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if filled.data[2][19] != red || filled.data[16][3] != red || filled.data[1][10] == red || filled.data[18][2] == red {
+		t.Error("filled triangle does not cover the expected in-bounds area")
+	}
+
+	outline := newPPM(20, 20, "P3", 255)
+	outline.DrawTriangle(p1, p2, p3, red)
+	for x := 2; x < 20; x++ {
+		if outline.data[2][x] != red {
+			t.Errorf("top edge pixel (%d, 2) is not drawn", x)
+		}
+	}
+	for y := 2; y <= 17; y++ {
+		if outline.data[y][2] != red {
+			t.Errorf("left edge pixel (2, %d) is not drawn", y)
+		}
+	}
+}
+
+func TestReadPPMCRLF(t *testing.T) {
+	ppm, err := ReadPPM("testdata/crlf.ppm")
+	if err != nil {
+		t.Fatalf("ReadPPM: %v", err)
+	}
+	if ppm.magicNumber != "P3" || ppm.width != 2 || ppm.height != 2 || ppm.max != 255 {
+		t.Errorf("header = %q %dx%d max %d, want \"P3\" 2x2 max 255", ppm.magicNumber, ppm.width, ppm.height, ppm.max)
+	}
+	want := [][]Pixel{{red, {0, 255, 0}}, {{0, 0, 255}, {10, 20, 30}}}
+	if !reflect.DeepEqual(ppm.data, want) {
+		t.Errorf("data = %v, want %v", ppm.data, want)
+	}
+
+	// Binary PPM is not supported by ReadPPM, but its CRLF header still decodes
+	if _, err := ReadPPM("testdata/crlf_p6.ppm"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("ReadPPM(P6) error = %v, want ErrUnsupportedFormat", err)
+	}
+	file, err := os.Open("testdata/crlf_p6.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	config, err := DecodeConfigPPM(file)
+	if err != nil {
+		t.Fatalf("DecodeConfigPPM(P6): %v", err)
+	}
+	if config.Width != 2 || config.Height != 2 {
+		t.Errorf("P6 config = %dx%d, want 2x2", config.Width, config.Height)
+	}
+}
+
+func TestDrawFilledPolygonRuleStar(t *testing.T) {
+	// A pentagram visits every second vertex of a regular pentagon, so its center is wound twice
+	var star []Point
+	for k := 0; k < 5; k++ {
+		angle := (-90 + 144*float64(k)) * math.Pi / 180
+		star = append(star, Point{20 + int(math.Round(18*math.Cos(angle))), 20 + int(math.Round(18*math.Sin(angle)))})
+	}
+
+	tip := Point{20, 6}
+	for _, tt := range []struct {
+		rule       WindingRule
+		wantCenter bool
+	}{{NonZero, true}, {EvenOdd, false}} {
+		ppm := newPPM(41, 41, "P3", 255)
+		ppm.DrawFilledPolygonRule(star, red, tt.rule)
+
+		if got := ppm.data[20][20] == red; got != tt.wantCenter {
+			t.Errorf("rule %d: center filled = %v, want %v", tt.rule, got, tt.wantCenter)
+		}
+		if ppm.data[tip.Y][tip.X] != red {
+			t.Errorf("rule %d: star tip %v is not filled", tt.rule, tip)
+		}
+		if ppm.data[0][0] == red || ppm.data[40][20] == red {
+			t.Errorf("rule %d: pixels outside the star are filled", tt.rule)
+		}
+	}
+}
+
+func TestDrawBitmap(t *testing.T) {
+	mask := newPBM(3, 2, "P1")
+	mask.data[0][0], mask.data[0][2], mask.data[1][1] = true, true, true
+
+	ppm := newPPM(6, 5, "P3", 255)
+	ppm.Clear(white)
+	ppm.DrawBitmap(mask, Point{2, 1}, red)
+
+	for y := 0; y < ppm.height; y++ {
+		for x := 0; x < ppm.width; x++ {
+			mx, my := x-2, y-1
+			want := white
+			if mx >= 0 && mx < 3 && my >= 0 && my < 2 && mask.data[my][mx] {
+				want = red
+			}
+			if got := ppm.data[y][x]; got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	// Stamps crossing the edges are clipped
+	ppm.DrawBitmap(mask, Point{4, 4}, red)
+	ppm.DrawBitmap(mask, Point{-2, 0}, red)
+	if ppm.data[4][4] != red || ppm.data[0][0] != red {
+		t.Error("clipped stamps did not draw their in-bounds pixels")
+	}
+}
+
+func TestToPGMLinearSaturated(t *testing.T) {
+	ppm := newPPM(4, 1, "P3", 255)
+	ppm.data[0] = []Pixel{red, {0, 0, 255}, {128, 128, 128}, white}
+	naive, linear := ppm.ToPGM(), ppm.ToPGMLinear()
+
+	// Pure red weighs 0.299 as encoded values but 0.2126 in linear light, which encodes back to about half brightness
+	if v := naive.data[0][0]; v < 75 || v > 77 {
+		t.Errorf("ToPGM(red) = %d, want about 76", v)
+	}
+	if v := linear.data[0][0]; v < 126 || v > 128 {
+		t.Errorf("ToPGMLinear(red) = %d, want about 127", v)
+	}
+	if linear.data[0][1] <= naive.data[0][1] {
+		t.Errorf("ToPGMLinear(blue) = %d, want brighter than ToPGM's %d", linear.data[0][1], naive.data[0][1])
+	}
+
+	// Neutral colors are unaffected
+	for x := 2; x < 4; x++ {
+		if d := int(linear.data[0][x]) - int(naive.data[0][x]); d < -1 || d > 1 {
+			t.Errorf("gray pixel %d: ToPGMLinear = %d, ToPGM = %d", x, linear.data[0][x], naive.data[0][x])
+		}
+	}
+}
+
+func TestCropToAspect(t *testing.T) {
+	original := patternPPM(100, 100)
+	ppm := original.clone()
+	if err := ppm.CropToAspect(16, 9); err != nil {
+		t.Fatalf("CropToAspect: %v", err)
+	}
+
+	if ppm.width != 100 || ppm.height != 56 || len(ppm.data) != 56 {
+		t.Fatalf("size = %dx%d, want 100x56", ppm.width, ppm.height)
+	}
+	// 44 rows are removed, 22 from the top and 22 from the bottom
+	for i := range ppm.data {
+		if !reflect.DeepEqual(ppm.data[i], original.data[22+i]) {
+			t.Fatalf("row %d is not row %d of the original", i, 22+i)
+		}
+	}
+
+	if err := ppm.CropToAspect(0, 9); err == nil {
+		t.Error("expected an error for an invalid ratio")
+	}
+}
+
+func TestMirrorExtend(t *testing.T) {
+	ppm := patternPPM(5, 3)
+	tile := ppm.MirrorExtend(true, false)
+
+	if tile.width != 10 || tile.height != 3 {
+		t.Fatalf("size = %dx%d, want 10x3", tile.width, tile.height)
+	}
+	for i := 0; i < tile.height; i++ {
+		for j := 0; j < 5; j++ {
+			if tile.data[i][j] != ppm.data[i][j] {
+				t.Errorf("left pixel (%d, %d) = %v, want the original %v", j, i, tile.data[i][j], ppm.data[i][j])
+			}
+			if tile.data[i][9-j] != tile.data[i][j] {
+				t.Errorf("right pixel (%d, %d) = %v, want the mirror %v", 9-j, i, tile.data[i][9-j], tile.data[i][j])
+			}
+		}
+	}
+
+	if both := ppm.MirrorExtend(true, true); both.width != 10 || both.height != 6 || both.data[5][9] != ppm.data[0][0] {
+		t.Errorf("extending both ways gives %dx%d, want a 10x6 mirrored tile", both.width, both.height)
+	}
+}
+
+func TestApplyLUTInvert(t *testing.T) {
+	var invert [256]uint8
+	for v := range invert {
+		invert[v] = uint8(255 - v)
+	}
+
+	lut, want := patternPPM(6, 5), patternPPM(6, 5)
+	lut.ApplyLUT(invert, invert, invert)
+	want.Invert()
+	if !reflect.DeepEqual(lut.data, want.data) {
+		t.Errorf("inversion LUT gives %v, want %v", lut.data, want.data)
+	}
+}
+
+func TestDiffHeatmap(t *testing.T) {
+	a := patternPPM(8, 8)
+	b := a.clone()
+
+	heatmap, err := DiffHeatmap(a, b)
+	if err != nil {
+		t.Fatalf("DiffHeatmap: %v", err)
+	}
+	if n := countColor(heatmap, Pixel{0, 0, 255}); n != 64 {
+		t.Errorf("%d blue pixels for identical images, want 64", n)
+	}
+
+	// Change a 2×2 region a lot
+	for i := 2; i < 4; i++ {
+		for j := 5; j < 7; j++ {
+			b.data[i][j] = Pixel{255 - a.data[i][j].R, 255 - a.data[i][j].G, 255 - a.data[i][j].B}
+		}
+	}
+	heatmap, err = DiffHeatmap(a, b)
+	if err != nil {
+		t.Fatalf("DiffHeatmap: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			p := heatmap.data[i][j]
+			if i >= 2 && i < 4 && j >= 5 && j < 7 {
+				if p.R < 200 || p.B > 50 {
+					t.Errorf("changed pixel (%d, %d) = %v, want a warm color", j, i, p)
+				}
+			} else if p != (Pixel{0, 0, 255}) {
+				t.Errorf("unchanged pixel (%d, %d) = %v, want blue", j, i, p)
+			}
+		}
+	}
+
+	if _, err := DiffHeatmap(a, patternPPM(4, 4)); err == nil {
+		t.Error("expected an error for mismatched sizes")
+	}
+}
+
+func TestPPMRotateCropWithInterpolation(t *testing.T) {
+	ramp := func() *PPM {
+		ppm := newPPM(33, 33, "P3", 255)
+		for i := range ppm.data {
+			for j := range ppm.data[i] {
+				v := uint8(7 * j)
+				ppm.data[i][j] = Pixel{v, v, 255 - v}
+			}
+		}
+		return ppm
+	}
+
+	nearest, bilinear := ramp(), ramp()
+	nearest.RotateCropWith(30, black, Nearest)
+	bilinear.RotateCropWith(30, black, Bilinear)
+
+	redPlane := func(ppm *PPM) [][]uint8 {
+		plane := make([][]uint8, ppm.height)
+		for i := range plane {
+			plane[i] = make([]uint8, ppm.width)
+			for j := range plane[i] {
+				plane[i][j] = ppm.data[i][j].R
+			}
+		}
+		return plane
+	}
+	if rn, rb := roughness(redPlane(nearest), 10), roughness(redPlane(bilinear), 10); rb >= rn {
+		t.Errorf("bilinear roughness = %v, want less than nearest's %v", rb, rn)
+	}
+}
+
+func TestCropCircle(t *testing.T) {
+	ppm := patternPPM(20, 20)
+	background := Pixel{1, 2, 3}
+	crop := ppm.CropCircle(Point{10, 9}, 4, background)
+
+	if crop.width != 9 || crop.height != 9 {
+		t.Fatalf("size = %dx%d, want 9x9", crop.width, crop.height)
+	}
+	for _, p := range []Point{{0, 0}, {8, 0}, {0, 8}, {8, 8}} {
+		if got := crop.data[p.Y][p.X]; got != background {
+			t.Errorf("corner %v = %v, want background %v", p, got, background)
+		}
+	}
+	for _, p := range []Point{{4, 4}, {4, 0}, {0, 4}, {6, 6}} {
+		if got, want := crop.data[p.Y][p.X], ppm.data[9-4+p.Y][10-4+p.X]; got != want {
+			t.Errorf("inside pixel %v = %v, want the original %v", p, got, want)
+		}
+	}
+}
+
+func TestRotateRegion(t *testing.T) {
+	original := patternPPM(8, 8)
+	ppm := original.clone()
+	r := Rect{2, 2, 4, 4}
+	if err := ppm.RotateRegion(r, 1); err != nil {
+		t.Fatalf("RotateRegion: %v", err)
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := original.data[y][x]
+			if r.Contains(Point{x, y}) {
+				// Clockwise: the left column of the region becomes its top row
+				i, j := y-r.Y, x-r.X
+				want = original.data[r.Y+r.Height-1-j][r.X+i]
+			}
+			if got := ppm.data[y][x]; got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if ppm.data[2][2] != original.data[5][2] {
+		t.Errorf("top-left of the region = %v, want the old bottom-left %v", ppm.data[2][2], original.data[5][2])
+	}
+
+	if err := ppm.RotateRegion(Rect{2, 2, 4, 3}, 1); err == nil {
+		t.Error("expected an error for a non-square quarter turn")
+	}
+	if err := ppm.RotateRegion(Rect{6, 6, 4, 4}, 2); err == nil {
+		t.Error("expected an error for a region outside the image")
+	}
+}
+
+func TestSimulateJPEG(t *testing.T) {
+	original := patternPPM(24, 20)
+	ppm := original.clone()
+	if err := ppm.SimulateJPEG(5); err != nil {
+		t.Fatalf("SimulateJPEG: %v", err)
+	}
+
+	if ppm.width != 24 || ppm.height != 20 || len(ppm.data) != 20 || len(ppm.data[0]) != 24 {
+		t.Fatalf("size = %dx%d, want 24x20", ppm.width, ppm.height)
+	}
+	if reflect.DeepEqual(ppm.data, original.data) {
+		t.Error("low quality compression left the image unchanged")
+	}
+
+	if err := ppm.SimulateJPEG(0); err == nil {
+		t.Error("expected an error for quality 0")
+	}
+	if err := ppm.SimulateJPEG(101); err == nil {
+		t.Error("expected an error for quality 101")
+	}
+}
+
+func TestOrient6(t *testing.T) {
+	ppm, want := patternPPM(5, 3), patternPPM(5, 3)
+	if err := ppm.Orient(6); err != nil {
+		t.Fatalf("Orient(6): %v", err)
+	}
+	want.Rotate90CW()
+
+	if ppm.width != 3 || ppm.height != 5 {
+		t.Fatalf("size = %dx%d, want 3x5", ppm.width, ppm.height)
+	}
+	if !reflect.DeepEqual(ppm.data, want.data) {
+		t.Errorf("Orient(6) = %v, want the 90° clockwise rotation %v", ppm.data, want.data)
+	}
+	// The bottom-left pixel ends up in the top-left corner
+	if ppm.data[0][0] != patternPPM(5, 3).data[2][0] {
+		t.Errorf("top-left pixel = %v, want the old bottom-left", ppm.data[0][0])
+	}
+
+	if err := ppm.Orient(9); err == nil {
+		t.Error("expected an error for orientation 9")
+	}
+}
+
+func TestDrawFilledSectorQuarter(t *testing.T) {
+	ppm := newPPM(21, 21, "P3", 255)
+	ppm.DrawFilledSector(Point{10, 10}, 8, 0, 90, red)
+
+	// From the positive X axis clockwise to the positive Y axis, which points down
+	for y := 0; y < 21; y++ {
+		for x := 0; x < 21; x++ {
+			dx, dy := x-10, y-10
+			want := dx >= 0 && dy >= 0 && dx*dx+dy*dy <= 64
+			if got := ppm.data[y][x] == red; got != want {
+				t.Errorf("pixel (%d, %d) filled = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	// A sector from 315° to 45° wraps around 0°
+	wrap := newPPM(21, 21, "P3", 255)
+	wrap.DrawFilledSector(Point{10, 10}, 8, 315, 45, red)
+	if wrap.data[10][15] != red || wrap.data[6][15] != red || wrap.data[14][15] != red {
+		t.Error("wrapping sector does not cover both sides of 0°")
+	}
+	if wrap.data[15][10] == red || wrap.data[5][10] == red || wrap.data[10][5] == red {
+		t.Error("wrapping sector covers angles outside [315°, 45°]")
+	}
+}
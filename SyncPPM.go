@@ -0,0 +1,42 @@
+package Netpbm
+
+import "sync"
+
+// SyncPPM wraps a PPM image so it can be shared between goroutines.
+type SyncPPM struct {
+	sync.RWMutex
+	ppm *PPM
+}
+
+// NewSyncPPM returns a SyncPPM that guards the given PPM image.
+func NewSyncPPM(ppm *PPM) *SyncPPM {
+	return &SyncPPM{ppm: ppm}
+}
+
+// Size returns the width and height of the image.
+func (s *SyncPPM) Size() (int, int) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.ppm.Size()
+}
+
+// At returns the value of the pixel at (x, y).
+func (s *SyncPPM) At(x, y int) Pixel {
+	s.RLock()
+	defer s.RUnlock()
+	return s.ppm.At(x, y)
+}
+
+// Set sets the value of the pixel at (x, y).
+func (s *SyncPPM) Set(x, y int, value Pixel) {
+	s.Lock()
+	defer s.Unlock()
+	s.ppm.Set(x, y, value)
+}
+
+// Draw calls fn with exclusive access to the underlying PPM image.
+func (s *SyncPPM) Draw(fn func(ppm *PPM)) {
+	s.Lock()
+	defer s.Unlock()
+	fn(s.ppm)
+}
@@ -0,0 +1,31 @@
+package Netpbm
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSyncPPMConcurrentSet is meant to be run with -race.
+func TestSyncPPMConcurrentSet(t *testing.T) {
+	s := NewSyncPPM(newPPM(16, 16, "P3", 255))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 16*16; i++ {
+				s.Set(i%16, i/16, Pixel{uint8(g), 0, 0})
+				s.At((i+1)%16, i/16)
+			}
+			s.Draw(func(ppm *PPM) {
+				ppm.DrawFilledCircle(Point{8, 8}, 3, Pixel{0, uint8(g), 0})
+			})
+		}(g)
+	}
+	wg.Wait()
+
+	if w, h := s.Size(); w != 16 || h != 16 {
+		t.Errorf("Size() = %d, %d, want 16, 16", w, h)
+	}
+}
@@ -0,0 +1,114 @@
+package Netpbm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"net/http"
+	"sync"
+)
+
+// DataURIPNG returns the PPM image encoded as PNG in a "data:image/png;base64,..." URI, for inlining in HTML.
+func (ppm *PPM) DataURIPNG() (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, ppm.ToImage()); err != nil {
+		return "", fmt.Errorf("error encoding PNG: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ServePPM writes the PPM image to an HTTP response, encoded as "png" or "ppm", with the matching Content-Type.
+func ServePPM(w http.ResponseWriter, ppm *PPM, format string) {
+	var buf bytes.Buffer
+	var contentType string
+	var err error
+
+	switch format {
+	case "png":
+		contentType = "image/png"
+		err = png.Encode(&buf, ppm.ToImage())
+	case "ppm":
+		contentType = "image/x-portable-pixmap"
+		err = ppm.encode(&buf, ASCIIOptions{Layout: LayoutPixel, MaxLineWidth: maxLineWidth})
+	default:
+		err = fmt.Errorf("unsupported format: %s", format)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
+
+// ImageCache renders PPM images on demand and keeps their PNG encoding, so each key is rendered only once.
+// Renders of different keys run concurrently; callers asking for a key being rendered wait for that render.
+type ImageCache struct {
+	mu      sync.Mutex
+	render  func(key string) (*PPM, error)
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry holds the result of rendering one key. done is closed once data and err are set.
+type cacheEntry struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// NewImageCache returns an empty ImageCache that renders missing images with render.
+func NewImageCache(render func(key string) (*PPM, error)) *ImageCache {
+	return &ImageCache{render: render, entries: make(map[string]*cacheEntry)}
+}
+
+// Get returns the PNG encoding of the image for key, rendering it on the first call.
+// Failed renders are not cached: callers waiting on them get the error, and the next call renders again.
+func (c *ImageCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		<-e.done
+		return e.data, e.err
+	}
+	e := &cacheEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.data, e.err = c.renderPNG(key)
+	if e.err != nil {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return e.data, e.err
+}
+
+// renderPNG renders the image for key and encodes it as PNG.
+func (c *ImageCache) renderPNG(key string) ([]byte, error) {
+	ppm, err := c.render(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, ppm.ToImage()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Serve writes the PNG image for key to an HTTP response.
+func (c *ImageCache) Serve(w http.ResponseWriter, key string) {
+	data, err := c.Get(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
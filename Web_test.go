@@ -0,0 +1,158 @@
+package Netpbm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestImageCacheRendersOnce(t *testing.T) {
+	var renders atomic.Int32
+	cache := NewImageCache(func(key string) (*PPM, error) {
+		renders.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return newPPM(4, 4, "P3", 255), nil
+	})
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 16)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := cache.Get("stage-1")
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = data
+		}(i)
+	}
+	wg.Wait()
+
+	if n := renders.Load(); n != 1 {
+		t.Errorf("rendered %d times, want 1", n)
+	}
+	for i, data := range results {
+		if !bytes.Equal(data, results[0]) {
+			t.Errorf("result %d differs from result 0", i)
+		}
+	}
+	if _, err := png.Decode(bytes.NewReader(results[0])); err != nil {
+		t.Errorf("cached data is not a PNG: %v", err)
+	}
+}
+
+func TestImageCacheKeysRenderConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	cache := NewImageCache(func(key string) (*PPM, error) {
+		if key == "slow" {
+			<-release
+		}
+		return newPPM(1, 1, "P3", 255), nil
+	})
+
+	go cache.Get("slow")
+	done := make(chan error)
+	go func() {
+		_, err := cache.Get("fast")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Get of another key waited behind a slow render")
+	}
+	close(release)
+}
+
+func TestImageCacheDoesNotCacheErrors(t *testing.T) {
+	errRender := errors.New("render failed")
+	fail := true
+	cache := NewImageCache(func(key string) (*PPM, error) {
+		if fail {
+			return nil, errRender
+		}
+		return newPPM(1, 1, "P3", 255), nil
+	})
+
+	if _, err := cache.Get("k"); !errors.Is(err, errRender) {
+		t.Fatalf("Get error = %v, want %v", err, errRender)
+	}
+	fail = false
+	if _, err := cache.Get("k"); err != nil {
+		t.Errorf("Get after a failed render: %v", err)
+	}
+}
+
+func TestServePPMPNG(t *testing.T) {
+	ppm := newPPM(3, 2, "P3", 255)
+	ppm.Set(1, 1, Pixel{255, 0, 0})
+
+	rec := httptest.NewRecorder()
+	ServePPM(rec, ppm, "png")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", ct)
+	}
+
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("response is not a valid PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 3 || b.Dy() != 2 {
+		t.Errorf("PNG is %dx%d, want 3x2", b.Dx(), b.Dy())
+	}
+	if r, g, _, _ := img.At(1, 1).RGBA(); r != 0xffff || g != 0 {
+		t.Errorf("pixel (1, 1) = %v, want red", img.At(1, 1))
+	}
+}
+
+func TestServePPMUnsupportedFormat(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ServePPM(rec, newPPM(1, 1, "P3", 255), "gif")
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestDataURIPNG(t *testing.T) {
+	ppm := patternPPM(5, 3)
+	uri, err := ppm.DataURIPNG()
+	if err != nil {
+		t.Fatalf("DataURIPNG: %v", err)
+	}
+
+	const prefix = "data:image/png;base64,"
+	if !strings.HasPrefix(uri, prefix) {
+		t.Fatalf("URI starts with %q, want %q", uri[:min(len(uri), len(prefix))], prefix)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, prefix))
+	if err != nil {
+		t.Fatalf("invalid base64: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 5 || b.Dy() != 3 {
+		t.Fatalf("PNG is %dx%d, want 5x3", b.Dx(), b.Dy())
+	}
+	r, g, b, _ := img.At(3, 2).RGBA()
+	if want := ppm.data[2][3]; uint8(r>>8) != want.R || uint8(g>>8) != want.G || uint8(b>>8) != want.B {
+		t.Errorf("PNG pixel (3, 2) = (%d %d %d), want %v", r>>8, g>>8, b>>8, want)
+	}
+}